@@ -26,79 +26,100 @@ SOFTWARE.
 package chacha
 
 import (
+	"runtime"
 	"sync"
 )
 
+// asyncChunkSize is the number of bytes processed
+// by a worker at once (1024 contiguous blocks)
+const asyncChunkSize = 1024 * blockSize
+
+// CipherData was the result of a single block encrypted by CipherAsync.
+//
+// Deprecated: CipherAsync writes the output of the workers directly
+// to the result and doesn't use CipherData anymore.
 type CipherData struct {
 	index int
 	data  []byte
 }
 
-func newEmptyCipherData() interface{} {
-	return new(CipherData)
+// SetWorkers sets the number of goroutines used by CipherAsync.
+// Zero or negative number means runtime.GOMAXPROCS(0), which is the default.
+func (cc *ChaCha) SetWorkers(n int) {
+	cc.workers = n
 }
 
-var cipherDataPool sync.Pool = sync.Pool{New: newEmptyCipherData}
-
-func init() {
-	for i := 0; i < 10; i++ {
-		cipherDataPool.Put(newEmptyCipherData())
+// Workers returns the number of goroutines used by CipherAsync
+func (cc *ChaCha) Workers() int {
+	if cc.workers <= 0 {
+		return runtime.GOMAXPROCS(0)
 	}
+	return cc.workers
 }
 
-// CipherAsync encryption/decryption using goroutines
+// CipherAsync encryption/decryption using goroutines.
+// The text is split into chunks of contiguous blocks processed
+// by a fixed pool of workers (see SetWorkers).
+// It panics with ErrCounterOverflow if the text is too long
+// for the block counter, use CipherAsyncChecked to get an error instead.
 func (cc *ChaCha) CipherAsync(text []byte) []byte {
-	nbytes := len(text)
-	blocksNumber := nbytes / blockSize
-	extraBlock := nbytes%blockSize != 0
-	goroutinesNumber := blocksNumber
-	if extraBlock {
-		goroutinesNumber++
+	cipherText, err := cc.CipherAsyncChecked(text)
+	if err != nil {
+		panic(err)
 	}
+	return cipherText
+}
 
-	cipherBuffer := make([]byte, nbytes)
-	dataChan := make(chan interface{}, goroutinesNumber)
-	defer close(dataChan)
-
-	var (
-		blockIndex int
-		byteIndex  int
-	)
-	for blockIndex < blocksNumber {
-		count := uint32(blockIndex) + cc.blockCount
-		processedText := text[byteIndex : byteIndex+64]
-		go cc.cipherBlock(count, processedText, byteIndex, dataChan)
-		blockIndex++
-		byteIndex += 64
+// CipherAsyncChecked encryption/decryption using goroutines.
+// Empty text gives empty result. It returns ErrCounterOverflow
+// if the text needs more blocks than the block counter
+// has left after the block count of the cipher object.
+func (cc *ChaCha) CipherAsyncChecked(text []byte) ([]byte, error) {
+	if err := cc.checkLength(len(text)); err != nil {
+		return nil, err
 	}
-	if extraBlock {
-		count := uint32(blockIndex) + cc.blockCount
-		processedText := text[byteIndex:]
-		go cc.cipherBlock(count, processedText, byteIndex, dataChan)
+	if len(text) == 0 {
+		return []byte{}, nil
 	}
+	return cc.cipherAsync(text), nil
+}
 
-	for i := 0; i < goroutinesNumber; i++ {
-		result := <-dataChan
-		cd := result.(*CipherData)
-		copy(cipherBuffer[cd.index:cd.index+len(cd.data)], cd.data)
-		cipherDataPool.Put(cd)
-	}
+func (cc *ChaCha) cipherAsync(text []byte) []byte {
+	nbytes := len(text)
+	cipherBuffer := make([]byte, nbytes)
 
-	return cipherBuffer
-}
+	chunksNumber := (nbytes + asyncChunkSize - 1) / asyncChunkSize
+	workersNumber := cc.Workers()
+	if workersNumber > chunksNumber {
+		workersNumber = chunksNumber
+	}
+	if workersNumber == 1 {
+		cc.cipherBlocks(cc.blockCount, cipherBuffer, text)
+		return cipherBuffer
+	}
 
-func (cc *ChaCha) cipherBlock(
-	counter uint32,
-	text []byte,
-	index int,
-	dataChan chan<- interface{},
-) {
-	state := cc.InitState(counter)
-	keyStream := Serialize(Block(state))
+	chunks := make(chan int, workersNumber)
+	var wg sync.WaitGroup
+	wg.Add(workersNumber)
+	for i := 0; i < workersNumber; i++ {
+		go func() {
+			defer wg.Done()
+			for byteIndex := range chunks {
+				end := byteIndex + asyncChunkSize
+				if end > nbytes {
+					end = nbytes
+				}
+				counter := cc.blockCount + uint64(byteIndex/blockSize)
+				cc.cipherBlocks(counter, cipherBuffer[byteIndex:end], text[byteIndex:end])
+			}
+		}()
+	}
 
-	cd := cipherDataPool.Get().(*CipherData)
-	cd.index = index
-	cd.data = xor(text, keyStream)
+	for byteIndex := 0; byteIndex < nbytes; byteIndex += asyncChunkSize {
+		chunks <- byteIndex
+	}
+	close(chunks)
+	wg.Wait()
 
-	dataChan <- cd
+	return cipherBuffer
 }
@@ -0,0 +1,58 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+import "math"
+
+// cipherBatches encrypts/decrypts bulk blocks of src starting with
+// passed block counter in batches of blocksPerBatch blocks and returns
+// the number of bytes processed. It stops before a batch which would
+// carry into word 13 (DJB variant), the rest goes block by block.
+func (cc *ChaCha) cipherBatches(counter uint64, dst, src []byte) int {
+	var keyStreamBatch [blocksPerBatch * blockSize]byte
+	processed := 0
+	for len(src)-processed >= len(keyStreamBatch) && canBatch(counter) {
+		cc.keyStreamBatch(&keyStreamBatch, counter)
+		processed += xorKeyStream(dst[processed:], src[processed:], keyStreamBatch[:])
+		counter += blocksPerBatch
+	}
+	return processed
+}
+
+// keyStreamBatch writes blocksPerBatch keystream blocks with
+// consecutive block counters starting with passed counter to out
+func (cc *ChaCha) keyStreamBatch(out *[blocksPerBatch * blockSize]byte, counter uint64) {
+	state := cc.state
+	cc.updateStateCounter(&state, counter)
+	blockRoundsBatch(out, &state, cc.rounds)
+}
+
+// canBatch reports if the blocks of a batch starting with passed
+// counter differ only in word 12 of the state. For the DJB variant
+// the carry into word 13 needs the single block path.
+func canBatch(counter uint64) bool {
+	return uint32(counter) <= math.MaxUint32-(blocksPerBatch-1)
+}
@@ -0,0 +1,101 @@
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+// blocksPerBatch is the number of consecutive blocks
+// computed at once by blockRoundsBatch
+const blocksPerBatch = 8
+
+// CPU features detected at startup, the tests switch them
+// off to cover every path on the same machine
+var (
+	useSSSE3 bool
+	useAVX2  bool
+)
+
+func init() {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 1 {
+		return
+	}
+
+	_, _, ecx1, _ := cpuid(1, 0)
+	useSSSE3 = ecx1&(1<<9) != 0
+
+	// AVX2 needs the OS to save YMM registers (OSXSAVE, XCR0 bits 1 and 2)
+	osAVX := ecx1&(1<<27) != 0 && ecx1&(1<<28) != 0
+	if osAVX {
+		xcr0, _ := xgetbv()
+		osAVX = xcr0&6 == 6
+	}
+	if osAVX && maxID >= 7 {
+		_, ebx7, _, _ := cpuid(7, 0)
+		useAVX2 = ebx7&(1<<5) != 0
+	}
+}
+
+// blockRoundsBatch computes keystream blocks of passed state with
+// consecutive counters starting with state[12] and writes them to out.
+// It uses AVX2 (8 blocks), SSSE3 (2 x 4 blocks) or the generic code.
+func blockRoundsBatch(out *[blocksPerBatch * blockSize]byte, state *[16]uint32, rounds int) {
+	if useAVX2 {
+		blocksAVX2(out, state, rounds)
+		return
+	}
+
+	high := *state
+	high[12] += 4
+	outLow := (*[4 * blockSize]byte)(out[:4*blockSize])
+	outHigh := (*[4 * blockSize]byte)(out[4*blockSize:])
+	if useSSSE3 {
+		blocksSSSE3(outLow, state, rounds)
+		blocksSSSE3(outHigh, &high, rounds)
+		return
+	}
+	blockRounds4(outLow, state, rounds)
+	blockRounds4(outHigh, &high, rounds)
+}
+
+// blocksSSSE3 computes 4 keystream blocks with
+// counters state[12], ..., state[12]+3
+//
+//go:noescape
+func blocksSSSE3(out *[4 * blockSize]byte, state *[16]uint32, rounds int)
+
+// blocksAVX2 computes 8 keystream blocks with
+// counters state[12], ..., state[12]+7
+//
+//go:noescape
+func blocksAVX2(out *[8 * blockSize]byte, state *[16]uint32, rounds int)
+
+//go:noescape
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+//go:noescape
+func xgetbv() (eax, edx uint32)
@@ -0,0 +1,302 @@
+// Copyright (c) 2021 Piotr Pszczółkowski. MIT License, see chacha.go.
+
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+#include "textflag.h"
+
+// The blocks are computed side by side: word i of the working state
+// of every block is in lane j of vector i, where j is the block index.
+// The 16 vectors of the working state stay in registers during the
+// rounds, except one which is spilled to its slot on the stack: the
+// freed register is the temporary of the rotations. Word 15 starts on
+// the stack, in the middle of every round word 8 and word 15 swap
+// places, so a double round has two stores and two loads.
+// The stack holds the working state (word i at slot i) for the output.
+
+// PSHUFB masks rotating every 32-bit word left by 16 and 8 bits
+DATA ·rotl16<>+0x00(SB)/8, $0x0504070601000302
+DATA ·rotl16<>+0x08(SB)/8, $0x0D0C0F0E09080B0A
+DATA ·rotl16<>+0x10(SB)/8, $0x0504070601000302
+DATA ·rotl16<>+0x18(SB)/8, $0x0D0C0F0E09080B0A
+GLOBL ·rotl16<>(SB), (NOPTR+RODATA), $32
+
+DATA ·rotl8<>+0x00(SB)/8, $0x0605040702010003
+DATA ·rotl8<>+0x08(SB)/8, $0x0E0D0C0F0A09080B
+DATA ·rotl8<>+0x10(SB)/8, $0x0605040702010003
+DATA ·rotl8<>+0x18(SB)/8, $0x0E0D0C0F0A09080B
+GLOBL ·rotl8<>(SB), (NOPTR+RODATA), $32
+
+// block counter offsets added to word 12
+DATA ·counterInc<>+0x00(SB)/8, $0x0000000100000000
+DATA ·counterInc<>+0x08(SB)/8, $0x0000000300000002
+DATA ·counterInc<>+0x10(SB)/8, $0x0000000500000004
+DATA ·counterInc<>+0x18(SB)/8, $0x0000000700000006
+GLOBL ·counterInc<>(SB), (NOPTR+RODATA), $32
+
+// ---------------------------------------------------------------------------
+// SSSE3, 4 blocks
+// ---------------------------------------------------------------------------
+
+// ROTL_SSE rotates 32-bit words of r left by n bits, t is clobbered
+#define ROTL_SSE(n, r, t) \
+	MOVO    r, t;        \
+	PSLLL   $n, t;       \
+	PSRLL   $(32-n), r;  \
+	PXOR    t, r
+
+// QR2_SSE computes two quarter rounds (a0, b0, c0, d0)
+// and (a1, b1, c1, d1), t is clobbered
+#define QR2_SSE(a0, b0, c0, d0, a1, b1, c1, d1, t)             \
+	PADDL  b0, a0; PADDL b1, a1;                               \
+	PXOR   a0, d0; PXOR a1, d1;                                \
+	PSHUFB ·rotl16<>(SB), d0; PSHUFB ·rotl16<>(SB), d1;        \
+	PADDL  d0, c0; PADDL d1, c1;                               \
+	PXOR   c0, b0; PXOR c1, b1;                                \
+	ROTL_SSE(12, b0, t); ROTL_SSE(12, b1, t);                  \
+	PADDL  b0, a0; PADDL b1, a1;                               \
+	PXOR   a0, d0; PXOR a1, d1;                                \
+	PSHUFB ·rotl8<>(SB), d0; PSHUFB ·rotl8<>(SB), d1;          \
+	PADDL  d0, c0; PADDL d1, c1;                               \
+	PXOR   c0, b0; PXOR c1, b1;                                \
+	ROTL_SSE(7, b0, t); ROTL_SSE(7, b1, t)
+
+// BROADCAST_SSE copies words of s to vectors w0, w1, w2 and s
+#define BROADCAST_SSE(s, w0, w1, w2) \
+	PSHUFD $0x00, s, w0;             \
+	PSHUFD $0x55, s, w1;             \
+	PSHUFD $0xaa, s, w2;             \
+	PSHUFD $0xff, s, s
+
+// OUTPUT_SSE adds initial state words s to working state words at
+// stack offset 4*off, transposes them and writes to every block at off
+#define OUTPUT_SSE(s, off)                               \
+	MOVOU (4*off+0x00)(SP), X0; PSHUFD $0x00, s, X4; PADDL X4, X0; \
+	MOVOU (4*off+0x10)(SP), X1; PSHUFD $0x55, s, X4; PADDL X4, X1; \
+	MOVOU (4*off+0x20)(SP), X2; PSHUFD $0xaa, s, X4; PADDL X4, X2; \
+	MOVOU (4*off+0x30)(SP), X3; PSHUFD $0xff, s, X4; PADDL X4, X3; \
+	TRANSPOSE_SSE(off)
+
+#define TRANSPOSE_SSE(off)                  \
+	MOVO       X0, X4;                      \
+	PUNPCKLLQ  X1, X0;                      \
+	PUNPCKHLQ  X1, X4;                      \
+	MOVO       X2, X5;                      \
+	PUNPCKLLQ  X3, X2;                      \
+	PUNPCKHLQ  X3, X5;                      \
+	MOVO       X0, X1;                      \
+	PUNPCKLQDQ X2, X0;                      \
+	PUNPCKHQDQ X2, X1;                      \
+	MOVO       X4, X3;                      \
+	PUNPCKLQDQ X5, X4;                      \
+	PUNPCKHQDQ X5, X3;                      \
+	MOVOU      X0, (off+0x00)(DI);          \
+	MOVOU      X1, (off+0x40)(DI);          \
+	MOVOU      X4, (off+0x80)(DI);          \
+	MOVOU      X3, (off+0xc0)(DI)
+
+// func blocksSSSE3(out *[4 * blockSize]byte, state *[16]uint32, rounds int)
+TEXT ·blocksSSSE3(SB), 0, $256-24
+	MOVQ out+0(FP), DI
+	MOVQ state+8(FP), SI
+	MOVQ rounds+16(FP), CX
+
+	MOVOU 0x00(SI), X3
+	MOVOU 0x10(SI), X7
+	MOVOU 0x20(SI), X11
+	MOVOU 0x30(SI), X15
+	BROADCAST_SSE(X3, X0, X1, X2)
+	BROADCAST_SSE(X7, X4, X5, X6)
+	BROADCAST_SSE(X11, X8, X9, X10)
+	BROADCAST_SSE(X15, X12, X13, X14)
+
+	// counters of the blocks
+	PADDL ·counterInc<>(SB), X12
+
+	MOVOU X15, 0xf0(SP)
+
+sseLoop:
+	// 'column' round, word 15 on the stack
+	QR2_SSE(X0, X4, X8, X12, X1, X5, X9, X13, X15)
+	MOVOU X8, 0x80(SP)
+	MOVOU 0xf0(SP), X15
+	QR2_SSE(X2, X6, X10, X14, X3, X7, X11, X15, X8)
+
+	// 'diagonal' round, word 8 on the stack
+	QR2_SSE(X0, X5, X10, X15, X1, X6, X11, X12, X8)
+	MOVOU X15, 0xf0(SP)
+	MOVOU 0x80(SP), X8
+	QR2_SSE(X2, X7, X8, X13, X3, X4, X9, X14, X15)
+	SUBQ $2, CX
+	JNZ  sseLoop
+
+	// counters of the blocks are added to word 12
+	PADDL ·counterInc<>(SB), X12
+
+	MOVOU X0, 0x00(SP)
+	MOVOU X1, 0x10(SP)
+	MOVOU X2, 0x20(SP)
+	MOVOU X3, 0x30(SP)
+	MOVOU X4, 0x40(SP)
+	MOVOU X5, 0x50(SP)
+	MOVOU X6, 0x60(SP)
+	MOVOU X7, 0x70(SP)
+	MOVOU X8, 0x80(SP)
+	MOVOU X9, 0x90(SP)
+	MOVOU X10, 0xa0(SP)
+	MOVOU X11, 0xb0(SP)
+	MOVOU X12, 0xc0(SP)
+	MOVOU X13, 0xd0(SP)
+	MOVOU X14, 0xe0(SP)
+
+	MOVOU 0x00(SI), X12
+	MOVOU 0x10(SI), X13
+	MOVOU 0x20(SI), X14
+	MOVOU 0x30(SI), X15
+	OUTPUT_SSE(X12, 0x00)
+	OUTPUT_SSE(X13, 0x10)
+	OUTPUT_SSE(X14, 0x20)
+	OUTPUT_SSE(X15, 0x30)
+	RET
+
+// ---------------------------------------------------------------------------
+// AVX2, 8 blocks
+// ---------------------------------------------------------------------------
+
+// ROTL_AVX rotates 32-bit words of r left by n bits, t is clobbered
+#define ROTL_AVX(n, r, t) \
+	VPSLLD $n, r, t;      \
+	VPSRLD $(32-n), r, r; \
+	VPOR   t, r, r
+
+// QR2_AVX computes two quarter rounds (a0, b0, c0, d0)
+// and (a1, b1, c1, d1), t is clobbered
+#define QR2_AVX(a0, b0, c0, d0, a1, b1, c1, d1, t)                    \
+	VPADDD  b0, a0, a0; VPADDD b1, a1, a1;                            \
+	VPXOR   a0, d0, d0; VPXOR a1, d1, d1;                             \
+	VPSHUFB ·rotl16<>(SB), d0, d0; VPSHUFB ·rotl16<>(SB), d1, d1;     \
+	VPADDD  d0, c0, c0; VPADDD d1, c1, c1;                            \
+	VPXOR   c0, b0, b0; VPXOR c1, b1, b1;                             \
+	ROTL_AVX(12, b0, t); ROTL_AVX(12, b1, t);                         \
+	VPADDD  b0, a0, a0; VPADDD b1, a1, a1;                            \
+	VPXOR   a0, d0, d0; VPXOR a1, d1, d1;                             \
+	VPSHUFB ·rotl8<>(SB), d0, d0; VPSHUFB ·rotl8<>(SB), d1, d1;       \
+	VPADDD  d0, c0, c0; VPADDD d1, c1, c1;                            \
+	VPXOR   c0, b0, b0; VPXOR c1, b1, b1;                             \
+	ROTL_AVX(7, b0, t); ROTL_AVX(7, b1, t)
+
+// OUTPUT_AVX adds initial state words to working state words at
+// stack offset 8*off, transposes them and writes to every block at off.
+// Blocks 0-3 are in the low lanes, blocks 4-7 in the high lanes.
+#define OUTPUT_AVX(off)                                                      \
+	VPBROADCASTD (off+0x0)(SI), Y4; VPADDD (8*off+0x00)(SP), Y4, Y0; \
+	VPBROADCASTD (off+0x4)(SI), Y4; VPADDD (8*off+0x20)(SP), Y4, Y1; \
+	VPBROADCASTD (off+0x8)(SI), Y4; VPADDD (8*off+0x40)(SP), Y4, Y2; \
+	VPBROADCASTD (off+0xc)(SI), Y4; VPADDD (8*off+0x60)(SP), Y4, Y3; \
+	TRANSPOSE_AVX(off)
+
+#define TRANSPOSE_AVX(off)                          \
+	VPUNPCKLDQ   Y1, Y0, Y4;                        \
+	VPUNPCKHDQ   Y1, Y0, Y5;                        \
+	VPUNPCKLDQ   Y3, Y2, Y6;                        \
+	VPUNPCKHDQ   Y3, Y2, Y7;                        \
+	VPUNPCKLQDQ  Y6, Y4, Y0;                        \
+	VPUNPCKHQDQ  Y6, Y4, Y1;                        \
+	VPUNPCKLQDQ  Y7, Y5, Y2;                        \
+	VPUNPCKHQDQ  Y7, Y5, Y3;                        \
+	VMOVDQU      X0, (off+0x000)(DI);               \
+	VMOVDQU      X1, (off+0x040)(DI);               \
+	VMOVDQU      X2, (off+0x080)(DI);               \
+	VMOVDQU      X3, (off+0x0c0)(DI);               \
+	VEXTRACTI128 $1, Y0, (off+0x100)(DI);           \
+	VEXTRACTI128 $1, Y1, (off+0x140)(DI);           \
+	VEXTRACTI128 $1, Y2, (off+0x180)(DI);           \
+	VEXTRACTI128 $1, Y3, (off+0x1c0)(DI)
+
+// func blocksAVX2(out *[8 * blockSize]byte, state *[16]uint32, rounds int)
+TEXT ·blocksAVX2(SB), 0, $512-24
+	MOVQ out+0(FP), DI
+	MOVQ state+8(FP), SI
+	MOVQ rounds+16(FP), CX
+
+	VPBROADCASTD 0x00(SI), Y0
+	VPBROADCASTD 0x04(SI), Y1
+	VPBROADCASTD 0x08(SI), Y2
+	VPBROADCASTD 0x0c(SI), Y3
+	VPBROADCASTD 0x10(SI), Y4
+	VPBROADCASTD 0x14(SI), Y5
+	VPBROADCASTD 0x18(SI), Y6
+	VPBROADCASTD 0x1c(SI), Y7
+	VPBROADCASTD 0x20(SI), Y8
+	VPBROADCASTD 0x24(SI), Y9
+	VPBROADCASTD 0x28(SI), Y10
+	VPBROADCASTD 0x2c(SI), Y11
+	VPBROADCASTD 0x30(SI), Y12
+	VPBROADCASTD 0x34(SI), Y13
+	VPBROADCASTD 0x38(SI), Y14
+	VPBROADCASTD 0x3c(SI), Y15
+
+	// counters of the blocks
+	VPADDD ·counterInc<>(SB), Y12, Y12
+
+	VMOVDQU Y15, 0x1e0(SP)
+
+avxLoop:
+	// 'column' round, word 15 on the stack
+	QR2_AVX(Y0, Y4, Y8, Y12, Y1, Y5, Y9, Y13, Y15)
+	VMOVDQU Y8, 0x100(SP)
+	VMOVDQU 0x1e0(SP), Y15
+	QR2_AVX(Y2, Y6, Y10, Y14, Y3, Y7, Y11, Y15, Y8)
+
+	// 'diagonal' round, word 8 on the stack
+	QR2_AVX(Y0, Y5, Y10, Y15, Y1, Y6, Y11, Y12, Y8)
+	VMOVDQU Y15, 0x1e0(SP)
+	VMOVDQU 0x100(SP), Y8
+	QR2_AVX(Y2, Y7, Y8, Y13, Y3, Y4, Y9, Y14, Y15)
+	SUBQ $2, CX
+	JNZ  avxLoop
+
+	// counters of the blocks are added to word 12
+	VPADDD ·counterInc<>(SB), Y12, Y12
+
+	VMOVDQU Y0, 0x000(SP)
+	VMOVDQU Y1, 0x020(SP)
+	VMOVDQU Y2, 0x040(SP)
+	VMOVDQU Y3, 0x060(SP)
+	VMOVDQU Y4, 0x080(SP)
+	VMOVDQU Y5, 0x0a0(SP)
+	VMOVDQU Y6, 0x0c0(SP)
+	VMOVDQU Y7, 0x0e0(SP)
+	VMOVDQU Y8, 0x100(SP)
+	VMOVDQU Y9, 0x120(SP)
+	VMOVDQU Y10, 0x140(SP)
+	VMOVDQU Y11, 0x160(SP)
+	VMOVDQU Y12, 0x180(SP)
+	VMOVDQU Y13, 0x1a0(SP)
+	VMOVDQU Y14, 0x1c0(SP)
+
+	OUTPUT_AVX(0x00)
+	OUTPUT_AVX(0x10)
+	OUTPUT_AVX(0x20)
+	OUTPUT_AVX(0x30)
+	VZEROUPPER
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
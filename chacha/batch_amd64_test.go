@@ -0,0 +1,167 @@
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+import (
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+// withFeatures runs f with passed CPU features, switched off
+// features make blockRoundsBatch use the next path
+func withFeatures(ssse3, avx2 bool, f func()) {
+	savedSSSE3, savedAVX2 := useSSSE3, useAVX2
+	defer func() {
+		useSSSE3, useAVX2 = savedSSSE3, savedAVX2
+	}()
+	useSSSE3 = useSSSE3 && ssse3
+	useAVX2 = useAVX2 && avx2
+	f()
+}
+
+// singleBlocks computes len(out)/64 keystream blocks of passed state
+// with consecutive counters one at a time, like the single block path
+func singleBlocks(out []byte, state [16]uint32, rounds int) {
+	for i := 0; i < len(out)/blockSize; i++ {
+		blockRounds((*[blockSize]byte)(out[i*blockSize:]), &state, rounds)
+		state[12]++
+	}
+}
+
+func Test_BlocksSSSE3(t *testing.T) {
+	if !useSSSE3 {
+		t.Skip("SSSE3 not supported")
+	}
+	for _, rounds := range []int{8, 12, 20} {
+		for _, counter := range []uint32{0, 1, 0xfffffffe} {
+			var state [16]uint32
+			for i := range state {
+				state[i] = uint32(i)*0x9e3779b9 + uint32(rounds)
+			}
+			state[12] = counter
+
+			var expected, out [4 * blockSize]byte
+			singleBlocks(expected[:], state, rounds)
+			blocksSSSE3(&out, &state, rounds)
+			if !shared.AreByteSlicesEqual(out[:], expected[:]) {
+				t.Errorf("%d rounds, counter %#x: invalid SSSE3 blocks", rounds, counter)
+			}
+		}
+	}
+}
+
+func Test_BlocksAVX2(t *testing.T) {
+	if !useAVX2 {
+		t.Skip("AVX2 not supported")
+	}
+	for _, rounds := range []int{8, 12, 20} {
+		for _, counter := range []uint32{0, 1, 0xfffffffa} {
+			var state [16]uint32
+			for i := range state {
+				state[i] = uint32(i)*0x9e3779b9 + uint32(rounds)
+			}
+			state[12] = counter
+
+			var expected, out [8 * blockSize]byte
+			singleBlocks(expected[:], state, rounds)
+			blocksAVX2(&out, &state, rounds)
+			if !shared.AreByteSlicesEqual(out[:], expected[:]) {
+				t.Errorf("%d rounds, counter %#x: invalid AVX2 blocks", rounds, counter)
+			}
+		}
+	}
+}
+
+// cipherPath selects a path of blockRoundsBatch by its CPU features
+type cipherPath struct {
+	name        string
+	ssse3, avx2 bool
+}
+
+var cipherPaths = []cipherPath{
+	{"generic", false, false},
+	{"SSSE3", true, false},
+	{"AVX2", true, true},
+}
+
+// skipUnsupported skips the test if the CPU lacks a feature
+// of the path, withFeatures would fall back to another path
+func (p cipherPath) skipUnsupported(tb testing.TB) {
+	if p.ssse3 && !useSSSE3 {
+		tb.Skip("SSSE3 not supported")
+	}
+	if p.avx2 && !useAVX2 {
+		tb.Skip("AVX2 not supported")
+	}
+}
+
+// Test_CipherPaths checks Cipher and the stream on every path
+// supported by the CPU against the single block path
+func Test_CipherPaths(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(50*blockSize + 13)
+	expected := make([]byte, len(plainText))
+	stream := cc.NewStream()
+	for i := range plainText {
+		stream.XORKeyStream(expected[i:i+1], plainText[i:i+1])
+	}
+
+	for _, p := range cipherPaths {
+		t.Run(p.name, func(t *testing.T) {
+			p.skipUnsupported(t)
+			withFeatures(p.ssse3, p.avx2, func() {
+				if !shared.AreByteSlicesEqual(cc.Cipher(plainText), expected) {
+					t.Error("invalid Cipher output")
+				}
+				cipherText := make([]byte, len(plainText))
+				cc.XORKeyStreamAt(cipherText[100:], plainText[100:], 100)
+				if !shared.AreByteSlicesEqual(cipherText[100:], expected[100:]) {
+					t.Error("invalid XORKeyStreamAt output")
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkCipherPaths(b *testing.B) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 1)
+	buffer := make([]byte, 64<<10)
+	for _, p := range cipherPaths {
+		b.Run(p.name, func(b *testing.B) {
+			p.skipUnsupported(b)
+			withFeatures(p.ssse3, p.avx2, func() {
+				b.SetBytes(int64(len(buffer)))
+				for n := 0; n < b.N; n++ {
+					cc.XORKeyStreamAt(buffer, buffer, 0)
+				}
+			})
+		})
+	}
+}
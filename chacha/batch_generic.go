@@ -0,0 +1,39 @@
+//go:build !amd64 || purego
+// +build !amd64 purego
+
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+// blocksPerBatch is the number of consecutive blocks
+// computed at once by blockRoundsBatch
+const blocksPerBatch = 4
+
+// blockRoundsBatch computes keystream blocks of passed state with
+// consecutive counters starting with state[12] and writes them to out
+func blockRoundsBatch(out *[blocksPerBatch * blockSize]byte, state *[16]uint32, rounds int) {
+	blockRounds4(out, state, rounds)
+}
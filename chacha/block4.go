@@ -0,0 +1,91 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+import "encoding/binary"
+
+// blockRounds4 computes 4 keystream blocks of passed state with
+// counters state[12], state[12]+1, state[12]+2 and state[12]+3
+// and writes them to out. The state is not modified.
+//
+// The blocks differ only in the counter, so quarter rounds of the first
+// column round which don't touch word 12 are computed once for the batch,
+// the remaining rounds block by block. Running 2 or 4 blocks in lockstep
+// in local variables is slower in pure Go, 32 or 64 words of state don't
+// fit in registers and are spilled.
+func blockRounds4(out *[4 * blockSize]byte, state *[16]uint32, rounds int) {
+	// first column round without column 0
+	p1, p5, p9, p13 := quarterRound(state[1], state[5], state[9], state[13])
+	p2, p6, p10, p14 := quarterRound(state[2], state[6], state[10], state[14])
+	p3, p7, p11, p15 := quarterRound(state[3], state[7], state[11], state[15])
+
+	for i := 0; i < 4; i++ {
+		counter := state[12] + uint32(i)
+
+		// column 0 of the first column round
+		x0, x4, x8, x12 := quarterRound(state[0], state[4], state[8], counter)
+		x1, x5, x9, x13 := p1, p5, p9, p13
+		x2, x6, x10, x14 := p2, p6, p10, p14
+		x3, x7, x11, x15 := p3, p7, p11, p15
+
+		// first diagonal round
+		x0, x5, x10, x15 = quarterRound(x0, x5, x10, x15)
+		x1, x6, x11, x12 = quarterRound(x1, x6, x11, x12)
+		x2, x7, x8, x13 = quarterRound(x2, x7, x8, x13)
+		x3, x4, x9, x14 = quarterRound(x3, x4, x9, x14)
+
+		for k := 2; k < rounds; k += 2 {
+			// 'column' round
+			x0, x4, x8, x12 = quarterRound(x0, x4, x8, x12)
+			x1, x5, x9, x13 = quarterRound(x1, x5, x9, x13)
+			x2, x6, x10, x14 = quarterRound(x2, x6, x10, x14)
+			x3, x7, x11, x15 = quarterRound(x3, x7, x11, x15)
+			// 'diagonal' round
+			x0, x5, x10, x15 = quarterRound(x0, x5, x10, x15)
+			x1, x6, x11, x12 = quarterRound(x1, x6, x11, x12)
+			x2, x7, x8, x13 = quarterRound(x2, x7, x8, x13)
+			x3, x4, x9, x14 = quarterRound(x3, x4, x9, x14)
+		}
+
+		block := out[i*blockSize : (i+1)*blockSize]
+		binary.LittleEndian.PutUint32(block[0:], x0+state[0])
+		binary.LittleEndian.PutUint32(block[4:], x1+state[1])
+		binary.LittleEndian.PutUint32(block[8:], x2+state[2])
+		binary.LittleEndian.PutUint32(block[12:], x3+state[3])
+		binary.LittleEndian.PutUint32(block[16:], x4+state[4])
+		binary.LittleEndian.PutUint32(block[20:], x5+state[5])
+		binary.LittleEndian.PutUint32(block[24:], x6+state[6])
+		binary.LittleEndian.PutUint32(block[28:], x7+state[7])
+		binary.LittleEndian.PutUint32(block[32:], x8+state[8])
+		binary.LittleEndian.PutUint32(block[36:], x9+state[9])
+		binary.LittleEndian.PutUint32(block[40:], x10+state[10])
+		binary.LittleEndian.PutUint32(block[44:], x11+state[11])
+		binary.LittleEndian.PutUint32(block[48:], x12+counter)
+		binary.LittleEndian.PutUint32(block[52:], x13+state[13])
+		binary.LittleEndian.PutUint32(block[56:], x14+state[14])
+		binary.LittleEndian.PutUint32(block[60:], x15+state[15])
+	}
+}
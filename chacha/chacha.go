@@ -25,102 +25,374 @@ SOFTWARE.
 */
 package chacha
 
-const blockSize int = 64 // in bytes
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	KeySize      = 32 // in bytes
+	NonceSize    = 12 // in bytes
+	DJBNonceSize = 8  // in bytes, nonce of the original variant
+
+	blockSize int = 64 // in bytes
+)
+
+// Variant selects the layout of the block counter
+// and the nonce in words 12-15 of the state
+type Variant int
+
+const (
+	// IETF is RFC 8439 layout: 32-bit counter (word 12), 96-bit nonce (words 13-15)
+	IETF Variant = iota
+	// DJB is the original layout: 64-bit counter (words 12-13), 64-bit nonce (words 14-15),
+	// used by libsodium crypto_stream_chacha20 and OpenSSH
+	DJB
+)
+
+// ErrCounterOverflow is returned when the text is too long for
+// the block counter. Wrapping the counter would reuse the keystream.
+var ErrCounterOverflow = errors.New("chacha: block counter overflow")
+
+// KeySizeError is returned for key of invalid length
+type KeySizeError int
+
+func (k KeySizeError) Error() string {
+	return "chacha: invalid key size " + strconv.Itoa(int(k))
+}
+
+// NonceSizeError is returned for nonce of invalid length
+type NonceSizeError int
+
+func (n NonceSizeError) Error() string {
+	return "chacha: invalid nonce size " + strconv.Itoa(int(n))
+}
 
 // ChaCha cipher object declaration
 type ChaCha struct {
-	key        []uint32 // A 256-bit key, 8 x uint32, 32 x byte
-	nonce      []uint32 // A 96-bit nonce, 3 x uint32 - Initialisation Vector
-	blockCount uint32   // A 32-bit block count parameter
+	state      [16]uint32 // constants, 256-bit key and nonce, the counter words are set for every block
+	blockCount uint64     // A 32-bit (IETF) or 64-bit (DJB) block count parameter
+	variant    Variant
+	rounds     int // 20, 12 or 8
+	workers    int // number of goroutines used by CipherAsync, 0 means GOMAXPROCS
 }
 
-// New creates new cipher object
-func New(key, nonce []byte, blockCount uint32) *ChaCha {
-	return &ChaCha{
-		key:        bytesToWords(key),
-		nonce:      bytesToWords(nonce),
+// NewCipher creates new cipher object. It returns KeySizeError
+// or NonceSizeError if the key is not 32 bytes or the nonce is not 12 bytes.
+func NewCipher(key, nonce []byte, blockCount uint32) (*ChaCha, error) {
+	if len(key) != KeySize {
+		return nil, KeySizeError(len(key))
+	}
+	if len(nonce) != NonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+
+	return newChaCha(key, nonce, uint64(blockCount), IETF), nil
+}
+
+// NewDJBCipher creates new cipher object of the original variant
+// with 64-bit nonce and 64-bit block counter. It returns KeySizeError
+// or NonceSizeError if the key is not 32 bytes or the nonce is not 8 bytes.
+func NewDJBCipher(key, nonce []byte, blockCount uint64) (*ChaCha, error) {
+	if len(key) != KeySize {
+		return nil, KeySizeError(len(key))
+	}
+	if len(nonce) != DJBNonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+
+	return newChaCha(key, nonce, blockCount, DJB), nil
+}
+
+func newChaCha(key, nonce []byte, blockCount uint64, variant Variant) *ChaCha {
+	cc := &ChaCha{
 		blockCount: blockCount,
+		variant:    variant,
+		rounds:     20,
+	}
+	setConstants(&cc.state)
+	for i := 0; i < 8; i++ {
+		cc.state[i+4] = bytes2word(key[4*i:])
+	}
+	nonceIndex := 13
+	if variant == DJB {
+		nonceIndex = 14
+	}
+	for i := 0; i < len(nonce)/4; i++ {
+		cc.state[i+nonceIndex] = bytes2word(nonce[4*i:])
 	}
+	return cc
 }
 
-// Cipher encrypts/decrypts passed bytes slice
-func (cc *ChaCha) Cipher(text []byte) []byte {
-	const BlockSize uint32 = 64 // in bytes
+// NewChaCha20 creates new cipher object with 20 rounds,
+// it is the same as NewCipher
+func NewChaCha20(key, nonce []byte, blockCount uint32) (*ChaCha, error) {
+	return NewCipher(key, nonce, blockCount)
+}
 
-	n := uint32(len(text))
-	blocksNumber := n / BlockSize // number of whole blocks
+// NewChaCha12 creates new cipher object with 12 rounds.
+// It is faster than ChaCha20 but has smaller security margin.
+func NewChaCha12(key, nonce []byte, blockCount uint32) (*ChaCha, error) {
+	return newReducedRounds(key, nonce, blockCount, 12)
+}
 
-	var (
-		cipherBuffer []byte
-		blockIndex   uint32
-		byteIndex    uint32
-	)
-	state := cc.InitState(0)
-	for blockIndex < blocksNumber {
-		block := updateStateCounter(state, blockIndex+cc.blockCount)
-		keyStream := Serialize(Block(block))
-		plainText := text[byteIndex : byteIndex+64]
-		cipher := xor(plainText, keyStream)
-		cipherBuffer = append(cipherBuffer, cipher...)
-		blockIndex++
-		byteIndex += 64
-	}
-	if n%BlockSize != 0 {
-		block := updateStateCounter(state, blockIndex+cc.blockCount)
-		keyStream := Serialize(Block(block))
-		plainText := text[byteIndex:]
-		cipher := xor(plainText, keyStream)
-		cipherBuffer = append(cipherBuffer, cipher...)
+// NewChaCha8 creates new cipher object with 8 rounds.
+// It is the fastest variant with the smallest security margin,
+// meant for non-cryptographic uses like shuffling or test data.
+func NewChaCha8(key, nonce []byte, blockCount uint32) (*ChaCha, error) {
+	return newReducedRounds(key, nonce, blockCount, 8)
+}
+
+func newReducedRounds(key, nonce []byte, blockCount uint32, rounds int) (*ChaCha, error) {
+	cc, err := NewCipher(key, nonce, blockCount)
+	if err != nil {
+		return nil, err
+	}
+	cc.rounds = rounds
+	return cc, nil
+}
+
+// New creates new cipher object.
+// It panics if the key or the nonce has invalid length, use NewCipher
+// to get an error instead.
+func New(key, nonce []byte, blockCount uint32) *ChaCha {
+	cc, err := NewCipher(key, nonce, blockCount)
+	if err != nil {
+		panic(err)
 	}
+	return cc
+}
+
+// Cipher encrypts/decrypts passed bytes slice.
+// It panics with ErrCounterOverflow if the text is too long
+// for the block counter, use CipherChecked to get an error instead.
+// The result is the only allocation (1 alloc/op), use XORKeyStreamAt
+// or Stream to encrypt into a buffer of the caller without allocating.
+func (cc *ChaCha) Cipher(text []byte) []byte {
+	cipherText, err := cc.CipherChecked(text)
+	if err != nil {
+		panic(err)
+	}
+	return cipherText
+}
+
+// CipherChecked encrypts/decrypts passed bytes slice.
+// Empty text gives empty result. It returns ErrCounterOverflow
+// if the text needs more blocks than the block counter
+// has left after the block count of the cipher object.
+func (cc *ChaCha) CipherChecked(text []byte) ([]byte, error) {
+	if err := cc.checkLength(len(text)); err != nil {
+		return nil, err
+	}
+	if len(text) == 0 {
+		return []byte{}, nil
+	}
+	return cc.cipher(text), nil
+}
+
+func (cc *ChaCha) cipher(text []byte) []byte {
+	cipherBuffer := make([]byte, len(text))
+	cc.cipherBlocks(cc.blockCount, cipherBuffer, text)
 	return cipherBuffer
 }
 
-func xor(a, b []byte) []byte {
-	n := len(a)
-	if n == 0 || n > len(b) {
-		panic("sizes of slices are invalid")
+// cipherBlocks encrypts/decrypts contiguous blocks of src starting
+// with passed block counter and writes the result to dst.
+// Bulk data goes through the batch path of the platform if there
+// is one, the rest block by block. It doesn't allocate.
+func (cc *ChaCha) cipherBlocks(counter uint64, dst, src []byte) {
+	n := cc.cipherBatches(counter, dst, src)
+	src = src[n:]
+	dst = dst[n:]
+	counter += uint64(n / blockSize)
+
+	var keyStream [blockSize]byte
+	for len(src) > 0 {
+		cc.keyStream(&keyStream, counter)
+		n := xorKeyStream(dst, src, keyStream[:])
+		src = src[n:]
+		dst = dst[n:]
+		counter++
 	}
-	cipher := make([]byte, n)
-	for i := 0; i < n; i++ {
-		cipher[i] = a[i] ^ b[i]
+}
+
+// keyStream writes keystream block with passed block counter to out
+func (cc *ChaCha) keyStream(out *[blockSize]byte, counter uint64) {
+	state := cc.state
+	cc.updateStateCounter(&state, counter)
+	blockRounds(out, &state, cc.rounds)
+}
+
+// xorKeyStream XORs src with the keystream, up to the length
+// of the keystream, writes the result to dst and returns
+// the number of bytes processed
+func xorKeyStream(dst, src, keyStream []byte) int {
+	n := len(keyStream)
+	if len(src) < n {
+		for i, v := range src {
+			dst[i] = v ^ keyStream[i]
+		}
+		return len(src)
 	}
-	return cipher
+
+	dst = dst[:n]
+	for i := 0; i < n; i += 8 {
+		v := binary.LittleEndian.Uint64(src[i:]) ^ binary.LittleEndian.Uint64(keyStream[i:])
+		binary.LittleEndian.PutUint64(dst[i:], v)
+	}
+	return n
 }
 
-// Block rotate passed state
-func Block(state []uint32) []uint32 {
-	workingState := dup(state)
+// checkLength checks if n bytes fit in the blocks
+// left after the block count of the cipher object
+func (cc *ChaCha) checkLength(n int) error {
+	return cc.checkLengthAt(0, n)
+}
 
-	for i := 0; i < 10; i++ {
-		innerBlock(workingState)
+// checkLengthAt checks if n bytes starting at passed byte offset
+// fit in the blocks left after the block count of the cipher object
+func (cc *ChaCha) checkLengthAt(offset uint64, n int) error {
+	if n == 0 {
+		return nil
 	}
+	last := (offset + uint64(n) - 1) / uint64(blockSize)
+	if last < offset/uint64(blockSize) || last > cc.maxCounter()-cc.blockCount {
+		return ErrCounterOverflow
+	}
+	return nil
+}
 
-	return add(state, workingState)
+// Variant returns the state layout of the cipher object
+func (cc *ChaCha) Variant() Variant {
+	return cc.variant
 }
 
-func innerBlock(state []uint32) {
-	// 'column' round
-	quarterRoundOnState(state, 0, 4, 8, 12)
-	quarterRoundOnState(state, 1, 5, 9, 13)
-	quarterRoundOnState(state, 2, 6, 10, 14)
-	quarterRoundOnState(state, 3, 7, 11, 15)
-	// 'diagonal' round
-	quarterRoundOnState(state, 0, 5, 10, 15)
-	quarterRoundOnState(state, 1, 6, 11, 12)
-	quarterRoundOnState(state, 2, 7, 8, 13)
-	quarterRoundOnState(state, 3, 4, 9, 14)
+// Rounds returns the number of rounds of the cipher object
+func (cc *ChaCha) Rounds() int {
+	return cc.rounds
 }
 
-func quarterRoundOnState(state []uint32, i0, i1, i2, i3 int) {
-	a := state[i0]
-	b := state[i1]
-	c := state[i2]
-	d := state[i3]
-	a, b, c, d = quarterRound(a, b, c, d)
-	state[i0] = a
-	state[i1] = b
-	state[i2] = c
-	state[i3] = d
+func (cc *ChaCha) maxCounter() uint64 {
+	if cc.variant == DJB {
+		return math.MaxUint64
+	}
+	return math.MaxUint32
+}
+
+// Block rotate passed state (20 rounds)
+func Block(state []uint32) []uint32 {
+	var (
+		s   [16]uint32
+		out [blockSize]byte
+	)
+	copy(s[:], state)
+	blockRounds(&out, &s, 20)
+
+	words := make([]uint32, 16)
+	for i := range words {
+		words[i] = bytes2word(out[4*i:])
+	}
+	return words
+}
+
+// blockRounds computes keystream block of passed state
+// and writes it to out. The state is not modified.
+func blockRounds(out *[blockSize]byte, state *[16]uint32, rounds int) {
+	x := *state
+	doubleRounds(&x, rounds)
+	for i, v := range x {
+		binary.LittleEndian.PutUint32(out[4*i:], v+state[i])
+	}
+}
+
+// doubleRounds applies rounds/2 double rounds, each is
+// a 'column' and a 'diagonal' round, to passed state.
+// The quarter rounds are written out and the four quarter rounds
+// of a round are interleaved step by step, they are independent.
+// Only the loop over the double rounds remains, as ChaCha8,
+// ChaCha12 and ChaCha20 share the function.
+func doubleRounds(state *[16]uint32, rounds int) {
+	x0, x1, x2, x3 := state[0], state[1], state[2], state[3]
+	x4, x5, x6, x7 := state[4], state[5], state[6], state[7]
+	x8, x9, x10, x11 := state[8], state[9], state[10], state[11]
+	x12, x13, x14, x15 := state[12], state[13], state[14], state[15]
+
+	for i := 0; i < rounds; i += 2 {
+		// 'column' round
+		x0 += x4
+		x1 += x5
+		x2 += x6
+		x3 += x7
+		x12 = rotl32(x12^x0, 16)
+		x13 = rotl32(x13^x1, 16)
+		x14 = rotl32(x14^x2, 16)
+		x15 = rotl32(x15^x3, 16)
+		x8 += x12
+		x9 += x13
+		x10 += x14
+		x11 += x15
+		x4 = rotl32(x4^x8, 12)
+		x5 = rotl32(x5^x9, 12)
+		x6 = rotl32(x6^x10, 12)
+		x7 = rotl32(x7^x11, 12)
+		x0 += x4
+		x1 += x5
+		x2 += x6
+		x3 += x7
+		x12 = rotl32(x12^x0, 8)
+		x13 = rotl32(x13^x1, 8)
+		x14 = rotl32(x14^x2, 8)
+		x15 = rotl32(x15^x3, 8)
+		x8 += x12
+		x9 += x13
+		x10 += x14
+		x11 += x15
+		x4 = rotl32(x4^x8, 7)
+		x5 = rotl32(x5^x9, 7)
+		x6 = rotl32(x6^x10, 7)
+		x7 = rotl32(x7^x11, 7)
+		// 'diagonal' round
+		x0 += x5
+		x1 += x6
+		x2 += x7
+		x3 += x4
+		x15 = rotl32(x15^x0, 16)
+		x12 = rotl32(x12^x1, 16)
+		x13 = rotl32(x13^x2, 16)
+		x14 = rotl32(x14^x3, 16)
+		x10 += x15
+		x11 += x12
+		x8 += x13
+		x9 += x14
+		x5 = rotl32(x5^x10, 12)
+		x6 = rotl32(x6^x11, 12)
+		x7 = rotl32(x7^x8, 12)
+		x4 = rotl32(x4^x9, 12)
+		x0 += x5
+		x1 += x6
+		x2 += x7
+		x3 += x4
+		x15 = rotl32(x15^x0, 8)
+		x12 = rotl32(x12^x1, 8)
+		x13 = rotl32(x13^x2, 8)
+		x14 = rotl32(x14^x3, 8)
+		x10 += x15
+		x11 += x12
+		x8 += x13
+		x9 += x14
+		x5 = rotl32(x5^x10, 7)
+		x6 = rotl32(x6^x11, 7)
+		x7 = rotl32(x7^x8, 7)
+		x4 = rotl32(x4^x9, 7)
+	}
+
+	state[0], state[1], state[2], state[3] = x0, x1, x2, x3
+	state[4], state[5], state[6], state[7] = x4, x5, x6, x7
+	state[8], state[9], state[10], state[11] = x8, x9, x10, x11
+	state[12], state[13], state[14], state[15] = x12, x13, x14, x15
 }
 
 func quarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
@@ -144,72 +416,33 @@ func quarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
 	return a, b, c, d
 }
 
-func updateStateCounter(state []uint32, counter uint32) []uint32 {
-	state[12] = counter
-	return state
+func (cc *ChaCha) updateStateCounter(state *[16]uint32, counter uint64) {
+	state[12] = uint32(counter)
+	if cc.variant == DJB {
+		state[13] = uint32(counter >> 32)
+	}
 }
 
 // InitState creates initial state with passed block count
 func (cc *ChaCha) InitState(blockCount uint32) []uint32 {
-	state := make([]uint32, 16)
-	// add constants
-	state[0] = 0x61707865
-	state[1] = 0x3320646e
-	state[2] = 0x79622d32
-	state[3] = 0x6b206574
-	// add key
-	for i, v := range cc.key {
-		state[i+4] = v
-	}
-	// add block count
-	state[12] = blockCount
-	// add nonce
-	for i, v := range cc.nonce {
-		state[i+13] = v
-	}
-
-	return state
+	return cc.initState(uint64(blockCount))
 }
 
-func add(a, b []uint32) []uint32 {
-	n := len(a)
-	if n == 0 || n != len(b) {
-		return nil
-	}
-
-	data := make([]uint32, n)
-	for i := 0; i < n; i++ {
-		data[i] = a[i] + b[i]
-	}
-	return data
+func (cc *ChaCha) initState(blockCount uint64) []uint32 {
+	state := cc.state
+	cc.updateStateCounter(&state, blockCount)
+	return state[:]
 }
 
-func dup(data []uint32) []uint32 {
-	buffer := make([]uint32, len(data))
-	copy(buffer, data)
-	return buffer
+func setConstants(state *[16]uint32) {
+	state[0] = 0x61707865
+	state[1] = 0x3320646e
+	state[2] = 0x79622d32
+	state[3] = 0x6b206574
 }
 
 func rotl32(v uint32, c int) uint32 {
-	return ((v << c) & 0xffffffff) | (v >> (32 - c))
-}
-
-func bytesToWords(data []byte) []uint32 {
-	size := len(data)
-	if size/4 == 0 || size%4 != 0 {
-		return nil
-	}
-
-	n := size / 4
-	words := make([]uint32, n)
-	i := 0 // bytes counter
-	k := 0 // words counter
-	for k < n {
-		words[k] = bytes2word(data[i:])
-		k++
-		i += 4
-	}
-	return words
+	return bits.RotateLeft32(v, c)
 }
 
 // Serialize converts uint32 slice to bytes slice
@@ -219,9 +452,9 @@ func Serialize(data []uint32) []byte {
 		return nil
 	}
 
-	out := make([]byte, 0, n*4)
-	for _, v := range data {
-		out = append(out, word2bytes(v)...)
+	out := make([]byte, n*4)
+	for i, v := range data {
+		word2bytes(out[4*i:], v)
 	}
 	return out
 }
@@ -230,11 +463,9 @@ func bytes2word(data []byte) uint32 {
 	return (uint32(data[3]) << 24) | (uint32(data[2]) << 16) | (uint32(data[1]) << 8) | uint32(data[0])
 }
 
-func word2bytes(w uint32) []byte {
-	out := []byte{0, 0, 0, 0}
+func word2bytes(out []byte, w uint32) {
 	out[3] = byte((w >> 24) & 0xff)
 	out[2] = byte((w >> 16) & 0xff)
 	out[1] = byte((w >> 8) & 0xff)
 	out[0] = byte(w & 0xff)
-	return out
 }
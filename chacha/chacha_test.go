@@ -26,11 +26,22 @@ SOFTWARE.
 package chacha
 
 import (
+	"encoding/hex"
+	"errors"
+	"strings"
 	"testing"
 
 	"ChaCha-Go/shared"
 )
 
+func fromHex(s string) []byte {
+	data, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
 func Test_rorl32(t *testing.T) {
 	value := uint32(0x7998bfda)
 	shift := 7
@@ -60,7 +71,9 @@ func Test_quarterRound(t *testing.T) {
 	t.Error("quarter round don't works")
 }
 
-func Test_quarterRoundOnState(t *testing.T) {
+// Test_quarterRoundDiagonal applies the quarter round
+// to words 2, 7, 8 and 13 of the state (RFC 8439, 2.2.1)
+func Test_quarterRoundDiagonal(t *testing.T) {
 	state := []uint32{
 		0x879531e0, 0xc5ecf37d, 0x516461b1, 0xc9a62f8a,
 		0x44c20ef3, 0x3390af7f, 0xd9fc690b, 0x2a5f714c,
@@ -74,10 +87,10 @@ func Test_quarterRoundOnState(t *testing.T) {
 		0x5c971061, 0xccc07c79, 0x2098d9d6, 0x91dbd320,
 	}
 
-	quarterRoundOnState(state, 2, 7, 8, 13)
+	state[2], state[7], state[8], state[13] = quarterRound(state[2], state[7], state[8], state[13])
 	for i, v := range state {
 		if v != stateExpectedAfter[i] {
-			t.Error("innerBlock don't work")
+			t.Error("quarter round on the state don't work")
 		}
 	}
 }
@@ -209,6 +222,494 @@ func Test_SyncAsync(t *testing.T) {
 	}
 }
 
+func Test_HChaCha20(t *testing.T) {
+	key := []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+	}
+	nonce := []byte{
+		0x00, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00, 0x4a,
+		0x00, 0x00, 0x00, 0x00, 0x31, 0x41, 0x59, 0x27,
+	}
+	expectedSubKey := []byte{
+		0x82, 0x41, 0x3b, 0x42, 0x27, 0xb2, 0x7b, 0xfe,
+		0xd3, 0x0e, 0x42, 0x50, 0x8a, 0x87, 0x7d, 0x73,
+		0xa0, 0xf9, 0xe4, 0xd5, 0x8a, 0x74, 0xa8, 0x53,
+		0xc1, 0x2e, 0xc4, 0x13, 0x26, 0xd3, 0xec, 0xdc,
+	}
+
+	subKey := HChaCha20(key, nonce)
+	if !shared.AreByteSlicesEqual(subKey, expectedSubKey) {
+		t.Error("invalid HChaCha20 subkey (draft-irtf-cfrg-xchacha 2.2.1)")
+	}
+}
+
+func Test_XChaCha20(t *testing.T) {
+	vectors := []struct {
+		name       string
+		key        []byte
+		nonce      []byte
+		counter    uint32
+		plainText  []byte
+		cipherText []byte
+	}{
+		{
+			name:    "draft-irtf-cfrg-xchacha A.3.2",
+			key:     fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f"),
+			nonce:   fromHex("404142434445464748494a4b4c4d4e4f5051525354555658"),
+			counter: 1,
+			plainText: []byte("The dhole (pronounced \"dole\") is also known as the Asiatic wild dog, red dog, and whistling dog. " +
+				"It is about the size of a German shepherd but looks more like a long-legged fox. " +
+				"This highly elusive and skilled jumper is classified with wolves, coyotes, jackals, and foxes in the taxonomic family Canidae."),
+			cipherText: fromHex(
+				"7d0a2e6b7f7c65a236542630294e063b7ab9b555a5d5149aa21e4ae1e4fbce87" +
+					"ecc8e08a8b5e350abe622b2ffa617b202cfad72032a3037e76ffdcdc4376ee05" +
+					"3a190d7e46ca1de04144850381b9cb29f051915386b8a710b8ac4d027b8b050f" +
+					"7cba5854e028d564e453b8a968824173fc16488b8970cac828f11ae53cabd201" +
+					"12f87107df24ee6183d2274fe4c8b1485534ef2c5fbc1ec24bfc3663efaa08bc" +
+					"047d29d25043532db8391a8a3d776bf4372a6955827ccb0cdd4af403a7ce4c63" +
+					"d595c75a43e045f0cce1f29c8b93bd65afc5974922f214a40b7c402cdb91ae73" +
+					"c0b63615cdad0480680f16515a7ace9d39236464328a37743ffc28f4ddb324f4" +
+					"d0f5bbdc270c65b1749a6efff1fbaa09536175ccd29fb9e6057b307320d31683" +
+					"8a9c71f70b5b5907a66f7ea49aadc409"),
+		},
+		{
+			name:      "libsodium xchacha20",
+			key:       fromHex("9d23bd4149cb979ccf3c5c94dd217e9808cb0e50cd0f67812235eaaf601d6232"),
+			nonce:     fromHex("c047548266b7c370d33566a2425cbf30d82d1eaf5294109e"),
+			plainText: make([]byte, 91),
+			cipherText: fromHex(
+				"a21209096594de8c5667b1d13ad93f744106d054df210e4782cd396fec692d35" +
+					"15a20bf351eec011a92c367888bc464c32f0807acd6c203a247e0db854148468" +
+					"e9f96bee4cf718d68d5f637cbd5a376457788e6fae90fc31097cfc"),
+		},
+	}
+
+	for _, v := range vectors {
+		cc := NewXChaCha20(v.key, v.nonce, v.counter)
+		cipherText := cc.Cipher(v.plainText)
+		if !shared.AreByteSlicesEqual(cipherText, v.cipherText) {
+			t.Errorf("%s: plain text -> cipher text failed", v.name)
+		}
+		if !shared.AreByteSlicesEqual(cc.Cipher(cipherText), v.plainText) {
+			t.Errorf("%s: cipher text -> plain text failed", v.name)
+		}
+	}
+}
+
+func Test_NewCipher(t *testing.T) {
+	key := make([]byte, KeySize)
+	nonce := make([]byte, NonceSize)
+
+	if _, err := NewCipher(key, nonce, 0); err != nil {
+		t.Errorf("valid sizes rejected: %v", err)
+	}
+
+	var keyErr KeySizeError
+	for _, n := range []int{0, 16, 31, 33} {
+		_, err := NewCipher(make([]byte, n), nonce, 0)
+		if !errors.As(err, &keyErr) || int(keyErr) != n {
+			t.Errorf("key of %d bytes: expected KeySizeError, got %v", n, err)
+		}
+	}
+
+	var nonceErr NonceSizeError
+	for _, n := range []int{0, 8, 11, 13, 24} {
+		_, err := NewCipher(key, make([]byte, n), 0)
+		if !errors.As(err, &nonceErr) || int(nonceErr) != n {
+			t.Errorf("nonce of %d bytes: expected NonceSizeError, got %v", n, err)
+		}
+	}
+
+	if _, err := NewXCipher(key, make([]byte, XNonceSize), 0); err != nil {
+		t.Errorf("valid XChaCha20 sizes rejected: %v", err)
+	}
+	if _, err := NewXCipher(key, nonce, 0); !errors.As(err, &nonceErr) {
+		t.Errorf("XChaCha20 nonce of 12 bytes: expected NonceSizeError, got %v", err)
+	}
+}
+
+func Test_NewPanics(t *testing.T) {
+	defer func() {
+		if _, ok := recover().(KeySizeError); !ok {
+			t.Error("New doesn't panic with KeySizeError")
+		}
+	}()
+	New(make([]byte, 31), make([]byte, NonceSize), 0)
+}
+
+func Test_CipherEmpty(t *testing.T) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 1)
+
+	if result := cc.Cipher(nil); len(result) != 0 {
+		t.Error("Cipher of empty text is not empty")
+	}
+	if result := cc.CipherAsync([]byte{}); len(result) != 0 {
+		t.Error("CipherAsync of empty text is not empty")
+	}
+	if result, err := cc.CipherChecked(nil); err != nil || result == nil || len(result) != 0 {
+		t.Error("CipherChecked of empty text failed")
+	}
+	if result, err := cc.CipherAsyncChecked(nil); err != nil || result == nil || len(result) != 0 {
+		t.Error("CipherAsyncChecked of empty text failed")
+	}
+}
+
+func Test_CipherCounterOverflow(t *testing.T) {
+	key := make([]byte, KeySize)
+	nonce := make([]byte, NonceSize)
+
+	// the last block (0xffffffff) is still available
+	cc := New(key, nonce, 0xffffffff)
+	if _, err := cc.CipherChecked(make([]byte, 64)); err != nil {
+		t.Errorf("last block rejected: %v", err)
+	}
+	if _, err := cc.CipherAsyncChecked(make([]byte, 64)); err != nil {
+		t.Errorf("last block rejected: %v", err)
+	}
+	if _, err := cc.CipherChecked(make([]byte, 65)); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("expected ErrCounterOverflow, got %v", err)
+	}
+	if _, err := cc.CipherAsyncChecked(make([]byte, 65)); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("expected ErrCounterOverflow, got %v", err)
+	}
+
+	cc = New(key, nonce, 0xfffffffe)
+	if _, err := cc.CipherChecked(make([]byte, 128)); err != nil {
+		t.Errorf("last two blocks rejected: %v", err)
+	}
+	if _, err := cc.CipherChecked(make([]byte, 129)); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("expected ErrCounterOverflow, got %v", err)
+	}
+
+	defer func() {
+		if recover() != ErrCounterOverflow {
+			t.Error("Cipher doesn't panic with ErrCounterOverflow")
+		}
+	}()
+	cc.Cipher(make([]byte, 129))
+}
+
+// djbVectors are keystreams of the original variant with 20 rounds
+// (draft-strombergson-chacha-test-vectors, TC1-TC8, 256-bit keys)
+var djbVectors = []struct {
+	name      string
+	key       []byte
+	nonce     []byte
+	keyStream []byte
+}{
+	{
+		name:  "TC1: all zero key and IV",
+		key:   make([]byte, 32),
+		nonce: make([]byte, 8),
+		keyStream: fromHex(
+			"76b8e0ada0f13d90405d6ae55386bd28bdd219b8a08ded1aa836efcc8b770dc7" +
+				"da41597c5157488d7724e03fb8d84a376a43b8f41518a11cc387b669b2ee6586" +
+				"9f07e7be5551387a98ba977c732d080dcb0f29a048e3656912c6533e32ee7aed" +
+				"29b721769ce64e43d57133b074d839d531ed1f28510afb45ace10a1f4b794d6f"),
+	},
+	{
+		name:  "TC2: single bit in key set, all zero IV",
+		key:   fromHex("0100000000000000000000000000000000000000000000000000000000000000"),
+		nonce: make([]byte, 8),
+		keyStream: fromHex(
+			"c5d30a7ce1ec119378c84f487d775a8542f13ece238a9455e8229e888de85bbd" +
+				"29eb63d0a17a5b999b52da22be4023eb07620a54f6fa6ad8737b71eb0464dac0"),
+	},
+	{
+		name:  "TC3: all zero key, single bit in IV set",
+		key:   make([]byte, 32),
+		nonce: fromHex("0100000000000000"),
+		keyStream: fromHex(
+			"ef3fdfd6c61578fbf5cf35bd3dd33b8009631634d21e42ac33960bd138e50d32" +
+				"111e4caf237ee53ca8ad6426194a88545ddc497a0b466e7d6bbdb0041b2f586b"),
+	},
+	{
+		name:  "TC4: all bits in key and IV are set",
+		key:   fromHex("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+		nonce: fromHex("ffffffffffffffff"),
+		keyStream: fromHex(
+			"d9bf3f6bce6ed0b54254557767fb57443dd4778911b606055c39cc25e674b836" +
+				"3feabc57fde54f790c52c8ae43240b79d49042b777bfd6cb80e931270b7f50eb"),
+	},
+	{
+		name:  "TC5: every even bit set in key and IV",
+		key:   fromHex("5555555555555555555555555555555555555555555555555555555555555555"),
+		nonce: fromHex("5555555555555555"),
+		keyStream: fromHex(
+			"bea9411aa453c5434a5ae8c92862f564396855a9ea6e22d6d3b50ae1b3663311" +
+				"a4a3606c671d605ce16c3aece8e61ea145c59775017bee2fa6f88afc758069f7"),
+	},
+	{
+		name:  "TC6: every odd bit set in key and IV",
+		key:   fromHex("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		nonce: fromHex("aaaaaaaaaaaaaaaa"),
+		keyStream: fromHex(
+			"9aa2a9f656efde5aa7591c5fed4b35aea2895dec7cb4543b9e9f21f5e7bcbcf3" +
+				"c43c748a970888f8248393a09d43e0b7e164bc4d0b0fb240a2d72115c4808906"),
+	},
+	{
+		name:  "TC7: sequence patterns in key and IV",
+		key:   fromHex("00112233445566778899aabbccddeeffffeeddccbbaa99887766554433221100"),
+		nonce: fromHex("0f1e2d3c4b5a6978"),
+		keyStream: fromHex(
+			"9fadf409c00811d00431d67efbd88fba59218d5d6708b1d685863fabbb0e961e" +
+				"ea480fd6fb532bfd494b2151015057423ab60a63fe4f55f7a212e2167ccab931"),
+	},
+	{
+		name:  "TC8: random key and IV",
+		key:   fromHex("c46ec1b18ce8a878725a37e780dfb7351f68ed2e194c79fbc6aebee1a667975d"),
+		nonce: fromHex("1ada31d5cf688221"),
+		keyStream: fromHex(
+			"f63a89b75c2271f9368816542ba52f06ed49241792302b00b5e8f80ae9a473af" +
+				"c25b218f519af0fdd406362e8d69de7f54c604a6e00f353f110f771bdca8ab92"),
+	},
+}
+
+func Test_DJBCipher(t *testing.T) {
+	for _, v := range djbVectors {
+		cc, err := NewDJBCipher(v.key, v.nonce, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cc.Variant() != DJB {
+			t.Fatal("invalid variant")
+		}
+		keyStream := cc.Cipher(make([]byte, len(v.keyStream)))
+		if !shared.AreByteSlicesEqual(keyStream, v.keyStream) {
+			t.Errorf("%s: invalid keystream", v.name)
+		}
+	}
+
+	key := make([]byte, KeySize)
+	if _, err := NewDJBCipher(key, make([]byte, NonceSize), 0); err == nil {
+		t.Error("DJB variant accepted 12-byte nonce")
+	}
+}
+
+func Test_DJBInitState(t *testing.T) {
+	key := []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+	}
+	nonce := []byte{
+		0x00, 0x00, 0x00, 0x4a, 0x00, 0x00, 0x00, 0x09,
+	}
+	cc, _ := NewDJBCipher(key, nonce, 0)
+
+	expectedState := []uint32{
+		0x61707865, 0x3320646e, 0x79622d32, 0x6b206574,
+		0x03020100, 0x07060504, 0x0b0a0908, 0x0f0e0d0c,
+		0x13121110, 0x17161514, 0x1b1a1918, 0x1f1e1d1c,
+		0xffffffff, 0x00000000, 0x4a000000, 0x09000000,
+	}
+	state := cc.InitState(0xffffffff)
+	if !shared.AreWordSlicesEqual(state, expectedState) {
+		t.Error("invalid DJB state with key setup")
+	}
+
+	// counter carry into word 13
+	expectedState[12] = 0x00000000
+	expectedState[13] = 0x00000001
+	state = cc.initState(0x100000000)
+	if !shared.AreWordSlicesEqual(state, expectedState) {
+		t.Error("invalid DJB state after counter carry")
+	}
+}
+
+// Test_DJBCounterCarry checks keystream across the 32-bit boundary of
+// the counter. The original variant with counter c and nonce n has the
+// same state as IETF variant with counter c & 0xffffffff and nonce c>>32 || n.
+func Test_DJBCounterCarry(t *testing.T) {
+	key := djbVectors[7].key
+	nonce := djbVectors[7].nonce
+
+	cc, _ := NewDJBCipher(key, nonce, 0xfffffffe)
+	keyStream := cc.Cipher(make([]byte, 4*blockSize))
+
+	ietfNonce := append([]byte{0x00, 0x00, 0x00, 0x00}, nonce...)
+	low := New(key, ietfNonce, 0xfffffffe).Cipher(make([]byte, 2*blockSize))
+	ietfNonce[0] = 0x01
+	high := New(key, ietfNonce, 0).Cipher(make([]byte, 2*blockSize))
+
+	if !shared.AreByteSlicesEqual(keyStream, append(low, high...)) {
+		t.Error("invalid keystream across counter carry")
+	}
+	if !shared.AreByteSlicesEqual(cc.CipherAsync(make([]byte, 4*blockSize)), keyStream) {
+		t.Error("invalid async keystream across counter carry")
+	}
+
+	stream := cc.NewStream()
+	stream.Seek(uint64(2*blockSize + 10))
+	buffer := make([]byte, 20)
+	stream.XORKeyStream(buffer, buffer)
+	if !shared.AreByteSlicesEqual(buffer, high[10:30]) {
+		t.Error("invalid stream keystream after counter carry")
+	}
+
+	// the 64-bit counter overflows only at 2^64
+	cc, _ = NewDJBCipher(key, nonce, 0xffffffffffffffff)
+	if _, err := cc.CipherChecked(make([]byte, blockSize)); err != nil {
+		t.Errorf("last block rejected: %v", err)
+	}
+	if _, err := cc.CipherChecked(make([]byte, blockSize+1)); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("expected ErrCounterOverflow, got %v", err)
+	}
+}
+
+func Test_ReducedRounds(t *testing.T) {
+	vectors := []struct {
+		name      string
+		rounds    int
+		key       []byte
+		keyStream []byte
+	}{
+		{
+			// draft-strombergson-chacha-test-vectors, TC1, 8 rounds
+			name:   "ChaCha8 all zero key",
+			rounds: 8,
+			key:    make([]byte, 32),
+			keyStream: fromHex(
+				"3e00ef2f895f40d67f5bb8e81f09a5a12c840ec3ce9a7f3b181be188ef711a1e" +
+					"984ce172b9216f419f445367456d5619314a42a3da86b001387bfdb80e0cfe42" +
+					"d2aefa0deaa5c151bf0adb6c01f2a5adc0fd581259f9a2aadcf20f8fd566a26b" +
+					"5032ec38bbc5da98ee0c6f568b872a65a08abf251deb21bb4b56e5d8821e68aa"),
+		},
+		{
+			// blocks 0 and 1 recovered from Go's math/rand/v2.ChaCha8 seeded with the key
+			name:   "ChaCha8 random key",
+			rounds: 8,
+			key:    fromHex("c46ec1b18ce8a878725a37e780dfb7351f68ed2e194c79fbc6aebee1a667975d"),
+			keyStream: fromHex(
+				"8d8904e976ae79e414ac5b19a83984347fa390d98db56f0d4e7cd262dcefe82d" +
+					"27ff5e455c3c2cd8501a781f5deb260250fd3201a3a49c469d5781aba5c32984" +
+					"ab9b3ba41abbcf9f514b9ee91a9ca792e95836d1a2ee297aea1aa28fbaf10dc4" +
+					"7ee8b5b44159e086e5e527823d3567faeb0c078e27c682e3ab499911033e709b"),
+		},
+		{
+			// draft-strombergson-chacha-test-vectors, TC1, 12 rounds
+			name:   "ChaCha12 all zero key",
+			rounds: 12,
+			key:    make([]byte, 32),
+			keyStream: fromHex(
+				"9bf49a6a0755f953811fce125f2683d50429c3bb49e074147e0089a52eae155f" +
+					"0564f879d27ae3c02ce82834acfa8c793a629f2ca0de6919610be82f411326be" +
+					"0bd58841203e74fe86fc71338ce0173dc628ebb719bdcbcc151585214cc089b4" +
+					"42258dcda14cf111c602b8971b8cc843e91e46ca905151c02744a6b017e69316"),
+		},
+		{
+			// draft-strombergson-chacha-test-vectors, TC1, 20 rounds
+			name:      "ChaCha20 all zero key",
+			rounds:    20,
+			key:       make([]byte, 32),
+			keyStream: djbVectors[0].keyStream,
+		},
+	}
+
+	constructors := map[int]func(key, nonce []byte, blockCount uint32) (*ChaCha, error){
+		8:  NewChaCha8,
+		12: NewChaCha12,
+		20: NewChaCha20,
+	}
+
+	for _, v := range vectors {
+		cc, err := constructors[v.rounds](v.key, make([]byte, NonceSize), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cc.Rounds() != v.rounds {
+			t.Fatalf("%s: invalid number of rounds %d", v.name, cc.Rounds())
+		}
+
+		plainText := make([]byte, len(v.keyStream))
+		if !shared.AreByteSlicesEqual(cc.Cipher(plainText), v.keyStream) {
+			t.Errorf("%s: invalid keystream", v.name)
+		}
+		if !shared.AreByteSlicesEqual(cc.CipherAsync(plainText), v.keyStream) {
+			t.Errorf("%s: invalid async keystream", v.name)
+		}
+		keyStream := make([]byte, len(plainText))
+		cc.NewStream().XORKeyStream(keyStream, plainText)
+		if !shared.AreByteSlicesEqual(keyStream, v.keyStream) {
+			t.Errorf("%s: invalid stream keystream", v.name)
+		}
+	}
+}
+
+// Test_AsyncWorkers checks if the worker pool produces the same
+// output as Cipher for sizes around the chunk boundaries
+func Test_AsyncWorkers(t *testing.T) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 7)
+	sizes := []int{1, 63, 64, 65, asyncChunkSize - 1, asyncChunkSize, asyncChunkSize + 1, 3*asyncChunkSize + 17}
+	plainText := make([]byte, sizes[len(sizes)-1])
+	for i := range plainText {
+		plainText[i] = byte(i * 13)
+	}
+
+	for _, workers := range []int{0, 1, 2, 3, 8} {
+		cc.SetWorkers(workers)
+		if workers > 0 && cc.Workers() != workers {
+			t.Fatalf("invalid number of workers %d", cc.Workers())
+		}
+		for _, n := range sizes {
+			expected := cc.Cipher(plainText[:n])
+			if !shared.AreByteSlicesEqual(cc.CipherAsync(plainText[:n]), expected) {
+				t.Errorf("%d workers, %d bytes: async output differs", workers, n)
+			}
+		}
+	}
+}
+
+// Test_KeyStreamBatch checks if batched blocks
+// are the same as blocks computed one at a time
+func Test_KeyStreamBatch(t *testing.T) {
+	for _, rounds := range []int{8, 12, 20} {
+		cc, _ := newReducedRounds(testPlainText(KeySize), testPlainText(NonceSize), 0, rounds)
+		for _, counter := range []uint64{0, 1, 0xfffffff8} {
+			var (
+				batch   [blocksPerBatch * blockSize]byte
+				blocks4 [4 * blockSize]byte
+				block   [blockSize]byte
+			)
+			cc.keyStreamBatch(&batch, counter)
+			state := cc.state
+			cc.updateStateCounter(&state, counter)
+			blockRounds4(&blocks4, &state, rounds)
+			for i := 0; i < blocksPerBatch; i++ {
+				cc.keyStream(&block, counter+uint64(i))
+				if !shared.AreByteSlicesEqual(batch[i*blockSize:(i+1)*blockSize], block[:]) {
+					t.Errorf("%d rounds, counter %#x: invalid batch block %d", rounds, counter, i)
+				}
+				if i < 4 && !shared.AreByteSlicesEqual(blocks4[i*blockSize:(i+1)*blockSize], block[:]) {
+					t.Errorf("%d rounds, counter %#x: invalid blockRounds4 block %d", rounds, counter, i)
+				}
+			}
+		}
+	}
+}
+
+// Test_CipherBatchTail checks lengths around
+// the boundary of the batch and the single block path
+func Test_CipherBatchTail(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(20 * blockSize)
+	expected := make([]byte, len(plainText))
+	stream := cc.NewStream()
+	for i := range plainText {
+		stream.XORKeyStream(expected[i:i+1], plainText[i:i+1])
+	}
+
+	for n := 0; n <= len(plainText); n += 7 {
+		if !shared.AreByteSlicesEqual(cc.Cipher(plainText[:n]), expected[:n]) {
+			t.Fatalf("invalid output for length %d", n)
+		}
+	}
+}
+
 // go test -bench=. -cpu 2,4,6,8 ./...
 
 func BenchmarkCiperSync(b *testing.B) {
@@ -309,4 +810,91 @@ func BenchmarkCiperAsync(b *testing.B) {
 		r = cc.CipherAsync([]byte(plainText))
 	}
 	result = r
-}
\ No newline at end of file
+}
+
+func benchmarkRounds(b *testing.B, newCipher func(key, nonce []byte, blockCount uint32) (*ChaCha, error)) {
+	cc, _ := newCipher(make([]byte, KeySize), make([]byte, NonceSize), 1)
+	plainText := make([]byte, 64*1024)
+
+	b.SetBytes(int64(len(plainText)))
+	for n := 0; n < b.N; n++ {
+		cc.Cipher(plainText)
+	}
+}
+
+func BenchmarkChaCha8(b *testing.B)  { benchmarkRounds(b, NewChaCha8) }
+func BenchmarkChaCha12(b *testing.B) { benchmarkRounds(b, NewChaCha12) }
+func BenchmarkChaCha20(b *testing.B) { benchmarkRounds(b, NewChaCha20) }
+
+// benchmarkSizes are message sizes from 1 KiB to 1 GiB
+var benchmarkSizes = []struct {
+	name string
+	size int
+}{
+	{"1KiB", 1 << 10},
+	{"64KiB", 64 << 10},
+	{"1MiB", 1 << 20},
+	{"16MiB", 16 << 20},
+	{"256MiB", 256 << 20},
+	{"1GiB", 1 << 30},
+}
+
+func benchmarkCipherSizes(b *testing.B, cipher func(cc *ChaCha, text []byte) []byte) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 1)
+	for _, bs := range benchmarkSizes {
+		b.Run(bs.name, func(b *testing.B) {
+			if testing.Short() && bs.size > 16<<20 {
+				b.Skip("skipping large message in short mode")
+			}
+			plainText := make([]byte, bs.size)
+			b.SetBytes(int64(bs.size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				result = cipher(cc, plainText)
+			}
+		})
+	}
+}
+
+// go test -run=NONE -bench=Sizes -cpu 1,2,4,8 ./chacha/
+
+func BenchmarkCipherSizes(b *testing.B) {
+	benchmarkCipherSizes(b, (*ChaCha).Cipher)
+}
+
+func BenchmarkCipherAsyncSizes(b *testing.B) {
+	benchmarkCipherSizes(b, (*ChaCha).CipherAsync)
+}
+
+// BenchmarkKeyStream1, BenchmarkKeyStream4 and BenchmarkKeyStreamBatch
+// compare throughput of the single block path, the generic
+// 4 block path and the batch path of the platform
+func BenchmarkKeyStream1(b *testing.B) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 0)
+	var block [blockSize]byte
+	b.SetBytes(4 * int64(blockSize))
+	for n := 0; n < b.N; n++ {
+		for i := uint64(0); i < 4; i++ {
+			cc.keyStream(&block, i)
+		}
+	}
+}
+
+func BenchmarkKeyStream4(b *testing.B) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 0)
+	var blocks [4 * blockSize]byte
+	b.SetBytes(4 * int64(blockSize))
+	for n := 0; n < b.N; n++ {
+		blockRounds4(&blocks, &cc.state, cc.rounds)
+	}
+}
+
+func BenchmarkKeyStreamBatch(b *testing.B) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 0)
+	var blocks [blocksPerBatch * blockSize]byte
+	b.SetBytes(blocksPerBatch * int64(blockSize))
+	for n := 0; n < b.N; n++ {
+		cc.keyStreamBatch(&blocks, 0)
+	}
+}
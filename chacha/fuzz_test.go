@@ -0,0 +1,202 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+
+	"golang.org/x/crypto/chacha20"
+
+	"ChaCha-Go/shared"
+)
+
+// Differential tests against golang.org/x/crypto/chacha20.
+// Run a fuzz target with e.g. go test -fuzz FuzzCipher ./chacha
+
+// overflows reports if n bytes starting at the block counter
+// need more blocks than the 32-bit counter has left
+func overflows(counter uint32, n int) bool {
+	return uint64(counter)+uint64((n+blockSize-1)/blockSize) > 1<<32
+}
+
+// referenceCipher returns data encrypted by x/crypto
+func referenceCipher(t *testing.T, key, nonce []byte, counter uint32, data []byte) []byte {
+	c, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetCounter(counter)
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+// referenceAt returns data encrypted by x/crypto from passed byte offset
+func referenceAt(t *testing.T, key, nonce []byte, counter uint32, data []byte, offset int) []byte {
+	padded := make([]byte, offset%blockSize+len(data))
+	copy(padded[offset%blockSize:], data)
+	return referenceCipher(t, key, nonce, counter+uint32(offset/blockSize), padded)[offset%blockSize:]
+}
+
+func FuzzCipher(f *testing.F) {
+	for _, n := range []int{0, 1, 63, 64, 65, 255, 256, 257, 511, 512, 513} {
+		f.Add(make([]byte, KeySize), make([]byte, NonceSize), uint32(1), testPlainText(n))
+	}
+	f.Add(make([]byte, KeySize), make([]byte, NonceSize), uint32(1<<32-1), testPlainText(64))
+	f.Add(make([]byte, KeySize), make([]byte, NonceSize), uint32(1<<32-1), testPlainText(65))
+
+	f.Fuzz(func(t *testing.T, key, nonce []byte, counter uint32, data []byte) {
+		key, nonce = shared.FitBytes(key, KeySize), shared.FitBytes(nonce, NonceSize)
+		cc := New(key, nonce, counter)
+
+		out, err := cc.CipherChecked(data)
+		if overflows(counter, len(data)) {
+			if err != ErrCounterOverflow {
+				t.Fatalf("counter %d, %d bytes: expected ErrCounterOverflow, got %v", counter, len(data), err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("counter %d, %d bytes: %v", counter, len(data), err)
+		}
+		if !shared.AreByteSlicesEqual(out, referenceCipher(t, key, nonce, counter, data)) {
+			t.Fatalf("counter %d, %d bytes: output differs from x/crypto", counter, len(data))
+		}
+		if !shared.AreByteSlicesEqual(cc.Cipher(out), data) {
+			t.Fatal("round trip failed")
+		}
+	})
+}
+
+func FuzzCipherAsync(f *testing.F) {
+	f.Add(make([]byte, KeySize), make([]byte, NonceSize), uint32(0), testPlainText(1000), uint8(70), uint8(3))
+	f.Add(make([]byte, KeySize), make([]byte, NonceSize), uint32(1<<32-2000), testPlainText(64), uint8(255), uint8(2))
+
+	f.Fuzz(func(t *testing.T, key, nonce []byte, counter uint32, data []byte, repeat, workers uint8) {
+		key, nonce = shared.FitBytes(key, KeySize), shared.FitBytes(nonce, NonceSize)
+		// repeated data crosses the boundaries of the chunks of the workers
+		data = bytes.Repeat(data, 1+int(repeat))
+		cc := New(key, nonce, counter)
+		cc.SetWorkers(1 + int(workers%4))
+
+		out, err := cc.CipherAsyncChecked(data)
+		if overflows(counter, len(data)) {
+			if err != ErrCounterOverflow {
+				t.Fatalf("counter %d, %d bytes: expected ErrCounterOverflow, got %v", counter, len(data), err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("counter %d, %d bytes: %v", counter, len(data), err)
+		}
+		if !shared.AreByteSlicesEqual(out, referenceCipher(t, key, nonce, counter, data)) {
+			t.Fatalf("counter %d, %d bytes, %d workers: output differs from x/crypto", counter, len(data), cc.Workers())
+		}
+	})
+}
+
+func FuzzStream(f *testing.F) {
+	f.Add(make([]byte, KeySize), make([]byte, NonceSize), uint32(1), testPlainText(300), []byte{1, 63, 64, 65, 0, 7}, uint16(100))
+	f.Add(make([]byte, KeySize), make([]byte, NonceSize), uint32(0), testPlainText(129), []byte{128}, uint16(64))
+
+	f.Fuzz(func(t *testing.T, key, nonce []byte, counter uint32, data, splits []byte, offset uint16) {
+		key, nonce = shared.FitBytes(key, KeySize), shared.FitBytes(nonce, NonceSize)
+		if overflows(counter, len(data)+int(offset)) {
+			return
+		}
+		cc := New(key, nonce, counter)
+		expected := referenceCipher(t, key, nonce, counter, data)
+
+		// pieces of lengths taken from splits, the rest at once
+		out := make([]byte, len(data))
+		s := cc.NewStream()
+		pos := 0
+		for _, n := range splits {
+			n := min(int(n), len(data)-pos)
+			s.XORKeyStream(out[pos:pos+n], data[pos:pos+n])
+			pos += n
+		}
+		s.XORKeyStream(out[pos:], data[pos:])
+		if !shared.AreByteSlicesEqual(out, expected) {
+			t.Fatalf("%d bytes in pieces %v: output differs from x/crypto", len(data), splits)
+		}
+
+		cc.XORKeyStreamAt(out, data, uint64(offset))
+		if !shared.AreByteSlicesEqual(out, referenceAt(t, key, nonce, counter, data, int(offset))) {
+			t.Fatalf("%d bytes at offset %d: output differs from x/crypto", len(data), offset)
+		}
+		s.Seek(uint64(offset))
+		s.XORKeyStream(out, data)
+		if !shared.AreByteSlicesEqual(out, referenceAt(t, key, nonce, counter, data, int(offset))) {
+			t.Fatalf("%d bytes after Seek(%d): output differs from x/crypto", len(data), offset)
+		}
+	})
+}
+
+// Test_Differential compares random keys, nonces, counters
+// and lengths around the block boundaries with x/crypto
+func Test_Differential(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	lengths := []int{0, 1, 63, 64, 65, 127, 128, 129, 255, 256, 257, 511, 512, 513, 1023, 1024, 1025}
+	for i := 0; i < 300; i++ {
+		key, nonce := make([]byte, KeySize), make([]byte, NonceSize)
+		for j := range key {
+			key[j] = byte(rng.Uint32())
+		}
+		for j := range nonce {
+			nonce[j] = byte(rng.Uint32())
+		}
+		n := lengths[i%len(lengths)]
+		if i%3 == 0 {
+			n = rng.IntN(5000)
+		}
+		counter := rng.Uint32()
+		if i%4 == 0 {
+			// close to the end of the counter
+			counter = 1<<32 - 1 - uint32(rng.IntN(20))
+		}
+		data := testPlainText(n)
+		if overflows(counter, n) {
+			continue
+		}
+
+		expected := referenceCipher(t, key, nonce, counter, data)
+		cc := New(key, nonce, counter)
+		if !shared.AreByteSlicesEqual(cc.Cipher(data), expected) {
+			t.Fatalf("Cipher: counter %d, %d bytes differ", counter, n)
+		}
+		if !shared.AreByteSlicesEqual(cc.CipherAsync(data), expected) {
+			t.Fatalf("CipherAsync: counter %d, %d bytes differ", counter, n)
+		}
+		out := make([]byte, n)
+		cc.NewStream().XORKeyStream(out, data)
+		if !shared.AreByteSlicesEqual(out, expected) {
+			t.Fatalf("Stream: counter %d, %d bytes differ", counter, n)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// parallelChunkSize is the number of bytes encrypted by a worker
+// of ParallelWriter and ParallelReader at once (16 async chunks)
+const parallelChunkSize = 16 * asyncChunkSize
+
+var errClosed = errors.New("chacha: use of closed parallel writer or reader")
+
+// chunk is a part of the stream processed by a worker
+type chunk struct {
+	buffer  []byte
+	n       int           // number of bytes in the buffer
+	counter uint64        // block count of the first block of the chunk
+	err     error         // reader error after the data of the chunk
+	done    chan struct{} // closed when the chunk is processed
+}
+
+// pipeline encrypts/decrypts chunks on a fixed pool of workers
+// and passes them in order to the consumer. The number of chunks
+// in flight, and so the memory used, is limited by the buffer pool.
+type pipeline struct {
+	cc        *ChaCha
+	chunkSize int
+	offset    uint64 // byte offset of the next chunk
+	jobs      chan *chunk
+	ordered   chan *chunk
+	buffers   chan []byte
+	allocated int // number of buffers allocated so far
+	workers   sync.WaitGroup
+}
+
+func newPipeline(cc *ChaCha, chunkSize int) *pipeline {
+	workersNumber := cc.Workers()
+	maxChunks := 2 * workersNumber
+	p := &pipeline{
+		cc:        cc,
+		chunkSize: chunkSize,
+		jobs:      make(chan *chunk, maxChunks),
+		ordered:   make(chan *chunk, maxChunks),
+		buffers:   make(chan []byte, maxChunks),
+	}
+
+	p.workers.Add(workersNumber)
+	for i := 0; i < workersNumber; i++ {
+		go func() {
+			defer p.workers.Done()
+			for c := range p.jobs {
+				p.cc.cipherBlocks(c.counter, c.buffer[:c.n], c.buffer[:c.n])
+				close(c.done)
+			}
+		}()
+	}
+	return p
+}
+
+// buffer returns a free buffer, it blocks
+// when all buffers of the pool are in flight
+func (p *pipeline) buffer() []byte {
+	if p.allocated < cap(p.buffers) {
+		select {
+		case buffer := <-p.buffers:
+			return buffer
+		default:
+			p.allocated++
+			return make([]byte, p.chunkSize)
+		}
+	}
+	return <-p.buffers
+}
+
+// submit passes n bytes of the buffer to the workers and the consumer.
+// It returns ErrCounterOverflow if the chunk doesn't fit in the counter.
+func (p *pipeline) submit(buffer []byte, n int, err error) error {
+	if cerr := p.cc.checkLengthAt(p.offset, n); cerr != nil {
+		p.buffers <- buffer
+		return cerr
+	}
+	c := &chunk{
+		buffer:  buffer,
+		n:       n,
+		counter: p.cc.blockCount + p.offset/uint64(blockSize),
+		err:     err,
+		done:    make(chan struct{}),
+	}
+	p.offset += uint64(n)
+	p.ordered <- c
+	p.jobs <- c
+	return nil
+}
+
+// stop closes the queues after the last chunk
+func (p *pipeline) stop() {
+	close(p.jobs)
+	close(p.ordered)
+}
+
+// ParallelWriter encrypts/decrypts everything written to it
+// and writes the result in order to the underlying writer.
+// Data is buffered into large chunks encrypted concurrently
+// by the workers of the cipher object (see SetWorkers).
+// Close must be called to flush the last chunk.
+type ParallelWriter struct {
+	p       *pipeline
+	w       io.Writer
+	current []byte // buffer being filled by Write
+	n       int    // number of bytes in current
+	closed  bool
+	done    chan struct{} // closed when the output goroutine ends
+
+	mu  sync.Mutex
+	err error // first error, returned by subsequent calls
+}
+
+// NewParallelWriter creates writer starting at the block count of the cipher object
+func (cc *ChaCha) NewParallelWriter(w io.Writer) *ParallelWriter {
+	return newParallelWriter(cc, w, parallelChunkSize)
+}
+
+func newParallelWriter(cc *ChaCha, w io.Writer, chunkSize int) *ParallelWriter {
+	pw := &ParallelWriter{
+		p:    newPipeline(cc, chunkSize),
+		w:    w,
+		done: make(chan struct{}),
+	}
+	go pw.output()
+	return pw
+}
+
+// output writes processed chunks in order. After a write error
+// it keeps draining the chunks, so the pipeline never blocks.
+func (pw *ParallelWriter) output() {
+	defer close(pw.done)
+	for c := range pw.p.ordered {
+		<-c.done
+		if pw.error() == nil {
+			if _, err := pw.w.Write(c.buffer[:c.n]); err != nil {
+				pw.setError(err)
+			}
+		}
+		pw.p.buffers <- c.buffer
+	}
+}
+
+// Write buffers p and passes full chunks to the workers. It returns
+// the first error of the underlying writer or ErrCounterOverflow
+// if the stream is too long for the block counter.
+func (pw *ParallelWriter) Write(p []byte) (int, error) {
+	if pw.closed {
+		return 0, errClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if err := pw.error(); err != nil {
+			return written, err
+		}
+		if pw.current == nil {
+			pw.current = pw.p.buffer()
+		}
+		k := copy(pw.current[pw.n:], p)
+		pw.n += k
+		p = p[k:]
+		if pw.n == len(pw.current) {
+			if err := pw.flush(); err != nil {
+				return written, err
+			}
+		}
+		written += k
+	}
+	return written, nil
+}
+
+func (pw *ParallelWriter) flush() error {
+	buffer, n := pw.current, pw.n
+	pw.current, pw.n = nil, 0
+	if err := pw.p.submit(buffer, n, nil); err != nil {
+		pw.setError(err)
+		return err
+	}
+	return nil
+}
+
+// Close flushes the buffered data and waits until everything
+// is written. It doesn't close the underlying writer.
+func (pw *ParallelWriter) Close() error {
+	if pw.closed {
+		return pw.error()
+	}
+	pw.closed = true
+
+	if pw.n > 0 && pw.error() == nil {
+		pw.flush()
+	}
+	pw.p.stop()
+	<-pw.done
+	pw.p.workers.Wait()
+	return pw.error()
+}
+
+func (pw *ParallelWriter) error() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.err
+}
+
+func (pw *ParallelWriter) setError(err error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.err == nil {
+		pw.err = err
+	}
+}
+
+// ParallelReader encrypts/decrypts data of the underlying reader.
+// It reads ahead large chunks encrypted concurrently by the workers
+// of the cipher object (see SetWorkers) and returns them in order.
+// Close stops reading ahead if the data is not read to the end.
+type ParallelReader struct {
+	p       *pipeline
+	current *chunk // chunk being returned by Read
+	offset  int    // index of the first unread byte of current
+	err     error  // error returned after all data
+	quit    chan struct{}
+	done    chan struct{} // closed when the input goroutine ends
+}
+
+// NewParallelReader creates reader starting at the block count of the cipher object
+func (cc *ChaCha) NewParallelReader(r io.Reader) *ParallelReader {
+	return newParallelReader(cc, r, parallelChunkSize)
+}
+
+func newParallelReader(cc *ChaCha, r io.Reader, chunkSize int) *ParallelReader {
+	pr := &ParallelReader{
+		p:    newPipeline(cc, chunkSize),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go pr.input(r)
+	return pr
+}
+
+// input reads chunks of r until an error or io.EOF
+func (pr *ParallelReader) input(r io.Reader) {
+	defer close(pr.done)
+	defer pr.p.stop()
+	for {
+		var buffer []byte
+		select {
+		case <-pr.quit:
+			return
+		default:
+			buffer = pr.p.buffer()
+		}
+
+		n, err := io.ReadFull(r, buffer)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if serr := pr.p.submit(buffer, n, err); serr != nil {
+			pr.p.ordered <- &chunk{err: serr, done: closedChannel}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read reads encrypted/decrypted data. Errors of the underlying reader,
+// or ErrCounterOverflow, are returned after the data read before them.
+func (pr *ParallelReader) Read(p []byte) (int, error) {
+	for pr.current == nil || pr.offset == pr.current.n {
+		if pr.current != nil {
+			if pr.current.err != nil {
+				pr.err = pr.current.err
+			}
+			if pr.current.buffer != nil {
+				pr.p.buffers <- pr.current.buffer
+			}
+			pr.current = nil
+		}
+		if pr.err != nil {
+			return 0, pr.err
+		}
+
+		c, ok := <-pr.p.ordered
+		if !ok {
+			pr.err = errClosed
+			return 0, pr.err
+		}
+		<-c.done
+		pr.current, pr.offset = c, 0
+	}
+
+	n := copy(p, pr.current.buffer[pr.offset:pr.current.n])
+	pr.offset += n
+	return n, nil
+}
+
+// Close stops reading ahead and waits for the workers.
+// It doesn't close the underlying reader.
+func (pr *ParallelReader) Close() error {
+	select {
+	case <-pr.quit:
+		return nil
+	default:
+	}
+	close(pr.quit)
+
+	// unblock the input goroutine waiting for a free buffer
+	// or a place in the queue, then drop the remaining chunks
+	for c := range pr.p.ordered {
+		<-c.done
+		if c.buffer != nil {
+			select {
+			case pr.p.buffers <- c.buffer:
+			default:
+			}
+		}
+	}
+	<-pr.done
+	pr.p.workers.Wait()
+	pr.current = nil
+	pr.err = errClosed
+	return nil
+}
+
+// closedChannel marks chunks without data as processed
+var closedChannel = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
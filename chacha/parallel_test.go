@@ -0,0 +1,194 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"ChaCha-Go/shared"
+)
+
+// testChunkSize is small to have many chunks in flight
+const testChunkSize = 4 * blockSize
+
+func Test_ParallelWriter(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(50*testChunkSize + 100)
+	expected := cc.Cipher(plainText)
+
+	for _, workers := range []int{1, 3, 8} {
+		cc.SetWorkers(workers)
+		for _, step := range []int{1, 63, testChunkSize, 1000, len(plainText)} {
+			var out bytes.Buffer
+			pw := newParallelWriter(cc, &out, testChunkSize)
+			for i := 0; i < len(plainText); i += step {
+				end := i + step
+				if end > len(plainText) {
+					end = len(plainText)
+				}
+				if n, err := pw.Write(plainText[i:end]); n != end-i || err != nil {
+					t.Fatalf("Write returned %d, %v", n, err)
+				}
+			}
+			if err := pw.Close(); err != nil {
+				t.Fatalf("Close returned %v", err)
+			}
+			if !shared.AreByteSlicesEqual(out.Bytes(), expected) {
+				t.Errorf("%d workers, writes of %d bytes: invalid output", workers, step)
+			}
+		}
+	}
+}
+
+func Test_ParallelReader(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(50*testChunkSize + 100)
+	expected := cc.Cipher(plainText)
+
+	readers := map[string]func(io.Reader) io.Reader{
+		"plain":    func(r io.Reader) io.Reader { return r },
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+	}
+	for _, workers := range []int{1, 3, 8} {
+		cc.SetWorkers(workers)
+		for name, reader := range readers {
+			pr := newParallelReader(cc, reader(bytes.NewReader(plainText)), testChunkSize)
+			out, err := io.ReadAll(iotest.OneByteReader(pr))
+			if err != nil {
+				t.Fatalf("ReadAll returned %v", err)
+			}
+			if !shared.AreByteSlicesEqual(out, expected) {
+				t.Errorf("%d workers, %s reader: invalid output", workers, name)
+			}
+			pr.Close()
+		}
+	}
+
+	// empty input
+	pr := cc.NewParallelReader(bytes.NewReader(nil))
+	if n, err := pr.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Errorf("empty input: Read returned %d, %v", n, err)
+	}
+}
+
+func Test_ParallelReaderError(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(3*testChunkSize + 10)
+	expected := cc.Cipher(plainText)
+	errRead := errors.New("read failed")
+
+	r := io.MultiReader(bytes.NewReader(plainText), iotest.ErrReader(errRead))
+	pr := newParallelReader(cc, r, testChunkSize)
+	defer pr.Close()
+	out, err := io.ReadAll(pr)
+	if err != errRead {
+		t.Errorf("expected the reader error, got %v", err)
+	}
+	if !shared.AreByteSlicesEqual(out, expected) {
+		t.Error("invalid output before the reader error")
+	}
+}
+
+func Test_ParallelReaderClose(t *testing.T) {
+	cc := newTestCipher()
+	cc.SetWorkers(2)
+	pr := newParallelReader(cc, bytes.NewReader(testPlainText(100*testChunkSize)), testChunkSize)
+	buffer := make([]byte, 10)
+	if _, err := io.ReadFull(pr, buffer); err != nil {
+		t.Fatalf("ReadFull returned %v", err)
+	}
+	if err := pr.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+	if _, err := pr.Read(buffer); err == nil {
+		t.Error("Read after Close doesn't fail")
+	}
+}
+
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 2 {
+		return 0, errors.New("write failed")
+	}
+	return len(p), nil
+}
+
+func Test_ParallelWriterError(t *testing.T) {
+	cc := newTestCipher()
+	pw := newParallelWriter(cc, &failingWriter{}, testChunkSize)
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		_, err = pw.Write(make([]byte, testChunkSize))
+	}
+	if cerr := pw.Close(); cerr == nil || (err != nil && cerr != err) {
+		t.Errorf("expected the writer error, got %v and %v", err, cerr)
+	}
+	if _, err := pw.Write([]byte{1}); err == nil {
+		t.Error("Write after Close doesn't fail")
+	}
+}
+
+func Test_ParallelCounterOverflow(t *testing.T) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 0xfffffffe)
+	pw := newParallelWriter(cc, io.Discard, testChunkSize)
+	if _, err := pw.Write(make([]byte, 2*blockSize+1)); err != nil {
+		t.Fatalf("Write returned %v", err)
+	}
+	if err := pw.Close(); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("expected ErrCounterOverflow, got %v", err)
+	}
+
+	pr := newParallelReader(cc, bytes.NewReader(make([]byte, 3*blockSize)), blockSize)
+	defer pr.Close()
+	out, err := io.ReadAll(pr)
+	if !errors.Is(err, ErrCounterOverflow) || len(out) != 2*blockSize {
+		t.Errorf("expected 2 blocks and ErrCounterOverflow, got %d bytes and %v", len(out), err)
+	}
+}
+
+func BenchmarkParallelWriter(b *testing.B) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 1)
+	buffer := make([]byte, 64<<10)
+	const size = 16 << 20
+	b.SetBytes(size)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		pw := cc.NewParallelWriter(io.Discard)
+		for i := 0; i < size; i += len(buffer) {
+			pw.Write(buffer)
+		}
+		pw.Close()
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+// Stream is stateful ChaCha20 keystream implementing cipher.Stream.
+// Unlike Cipher, subsequent calls of XORKeyStream continue
+// from the position where the previous call stopped.
+type Stream struct {
+	cc        *ChaCha
+	base      uint64          // block count of the first keystream block (offset 0)
+	counter   uint64          // block count of the next keystream block
+	exhausted bool            // the block counter has no blocks left
+	keyStream [blockSize]byte // current keystream block
+	offset    int             // index of the first unused byte in keyStream
+}
+
+// NewStream creates stream starting at the block count of the cipher object
+func (cc *ChaCha) NewStream() *Stream {
+	return &Stream{
+		cc:      cc,
+		base:    cc.blockCount,
+		counter: cc.blockCount,
+		offset:  blockSize,
+	}
+}
+
+// XORKeyStream XORs each byte in src with a byte from the keystream
+// and writes the result to dst. Dst and src must overlap entirely or not at all.
+// It panics with ErrCounterOverflow when the block counter is exhausted.
+func (s *Stream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("chacha: output smaller than input")
+	}
+
+	for len(src) > 0 {
+		if s.offset == blockSize {
+			if len(src) >= blockSize && !s.exhausted {
+				n := s.wholeBlocks(dst, src)
+				src = src[n:]
+				dst = dst[n:]
+				continue
+			}
+			s.nextBlock()
+		}
+		n := blockSize - s.offset
+		if n > len(src) {
+			n = len(src)
+		}
+		keyStream := s.keyStream[s.offset : s.offset+n]
+		for i, v := range keyStream {
+			dst[i] = src[i] ^ v
+		}
+		s.offset += n
+		src = src[n:]
+		dst = dst[n:]
+	}
+}
+
+// SetCounter moves the stream to the beginning
+// of the keystream block with passed block count
+func (s *Stream) SetCounter(counter uint64) {
+	s.counter = counter
+	s.exhausted = counter > s.cc.maxCounter()
+	s.offset = blockSize
+}
+
+// Seek moves the stream to passed byte offset. The offset is counted
+// from the beginning of the block count of the cipher object.
+func (s *Stream) Seek(offset uint64) {
+	blocks := offset / uint64(blockSize)
+	if blocks > s.cc.maxCounter()-s.base {
+		s.exhausted = true
+		s.offset = blockSize
+		return
+	}
+
+	s.SetCounter(s.base + blocks)
+	if rest := int(offset % uint64(blockSize)); rest != 0 {
+		s.nextBlock()
+		s.offset = rest
+	}
+}
+
+// XORKeyStreamAt encrypts/decrypts src as if it started at passed
+// byte offset of the message and writes the result to dst.
+// Blocks before the offset are not computed.
+func (cc *ChaCha) XORKeyStreamAt(dst, src []byte, offset uint64) {
+	s := cc.NewStream()
+	s.Seek(offset)
+	s.XORKeyStream(dst, src)
+}
+
+func (s *Stream) nextBlock() {
+	if s.exhausted {
+		panic(ErrCounterOverflow)
+	}
+	s.cc.keyStream(&s.keyStream, s.counter)
+	s.advance(1)
+	s.offset = 0
+}
+
+// wholeBlocks encrypts/decrypts whole blocks of src without
+// buffering the keystream and returns the number of bytes processed.
+// The number of blocks is limited to the blocks left in the counter.
+func (s *Stream) wholeBlocks(dst, src []byte) int {
+	blocks := uint64(len(src) / blockSize)
+	if left := s.cc.maxCounter() - s.counter; blocks-1 > left {
+		blocks = left + 1
+	}
+	n := int(blocks) * blockSize
+	s.cc.cipherBlocks(s.counter, dst[:n], src[:n])
+	s.advance(blocks)
+	return n
+}
+
+// advance moves the counter by passed number of blocks,
+// which must not exceed the blocks left in the counter
+func (s *Stream) advance(blocks uint64) {
+	if blocks-1 == s.cc.maxCounter()-s.counter {
+		s.exhausted = true
+	} else {
+		s.counter += blocks
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+// interface check
+var _ cipher.Stream = (*Stream)(nil)
+
+func newTestCipher() *ChaCha {
+	key := []byte{
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+	}
+	nonce := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x4a,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	return New(key, nonce, 1)
+}
+
+func testPlainText(n int) []byte {
+	text := make([]byte, n)
+	for i := range text {
+		text[i] = byte(i*31 + 7)
+	}
+	return text
+}
+
+// Test_StreamSplit checks if a message split in two
+// calls gives the same output as Cipher
+func Test_StreamSplit(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(300)
+	expected := cc.Cipher(plainText)
+
+	for split := 0; split <= len(plainText); split++ {
+		stream := cc.NewStream()
+		cipherText := make([]byte, len(plainText))
+		stream.XORKeyStream(cipherText[:split], plainText[:split])
+		stream.XORKeyStream(cipherText[split:], plainText[split:])
+		if !shared.AreByteSlicesEqual(cipherText, expected) {
+			t.Fatalf("invalid output for split at %d", split)
+		}
+	}
+}
+
+// Test_StreamSmallWrites checks writes of every size up to two blocks
+func Test_StreamSmallWrites(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(1000)
+	expected := cc.Cipher(plainText)
+
+	for step := 1; step <= 2*blockSize; step++ {
+		stream := cc.NewStream()
+		cipherText := make([]byte, len(plainText))
+		for i := 0; i < len(plainText); i += step {
+			end := i + step
+			if end > len(plainText) {
+				end = len(plainText)
+			}
+			stream.XORKeyStream(cipherText[i:end], plainText[i:end])
+		}
+		if !shared.AreByteSlicesEqual(cipherText, expected) {
+			t.Fatalf("invalid output for step %d", step)
+		}
+	}
+}
+
+func Test_StreamInPlace(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(200)
+	expected := cc.Cipher(plainText)
+
+	buffer := make([]byte, len(plainText))
+	copy(buffer, plainText)
+	cc.NewStream().XORKeyStream(buffer, buffer)
+	if !shared.AreByteSlicesEqual(buffer, expected) {
+		t.Error("in-place encryption failed")
+	}
+}
+
+func Test_StreamReaderWriter(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(5000)
+
+	var encrypted bytes.Buffer
+	writer := cipher.StreamWriter{S: cc.NewStream(), W: &encrypted}
+	if _, err := io.Copy(writer, bytes.NewReader(plainText)); err != nil {
+		t.Fatal(err)
+	}
+	if !shared.AreByteSlicesEqual(encrypted.Bytes(), cc.Cipher(plainText)) {
+		t.Error("StreamWriter output differs from Cipher")
+	}
+
+	reader := cipher.StreamReader{S: cc.NewStream(), R: &encrypted}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shared.AreByteSlicesEqual(decrypted, plainText) {
+		t.Error("StreamReader output differs from plain text")
+	}
+}
+
+// Test_XORKeyStreamAt checks ranges starting at every offset
+// against the same range of the whole encrypted message
+func Test_XORKeyStreamAt(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(700)
+	expected := cc.Cipher(plainText)
+
+	for offset := 0; offset < len(plainText); offset++ {
+		for _, n := range []int{1, 63, 64, 65, 200} {
+			end := offset + n
+			if end > len(plainText) {
+				end = len(plainText)
+			}
+			cipherText := make([]byte, end-offset)
+			cc.XORKeyStreamAt(cipherText, plainText[offset:end], uint64(offset))
+			if !shared.AreByteSlicesEqual(cipherText, expected[offset:end]) {
+				t.Fatalf("invalid output at offset %d, length %d", offset, n)
+			}
+		}
+	}
+}
+
+func Test_StreamSeek(t *testing.T) {
+	cc := newTestCipher()
+	plainText := testPlainText(500)
+	expected := cc.Cipher(plainText)
+
+	stream := cc.NewStream()
+	cipherText := make([]byte, len(plainText))
+	// backwards, in pieces of 50 bytes
+	for offset := 450; offset >= 0; offset -= 50 {
+		stream.Seek(uint64(offset))
+		stream.XORKeyStream(cipherText[offset:offset+50], plainText[offset:offset+50])
+	}
+	if !shared.AreByteSlicesEqual(cipherText, expected) {
+		t.Error("invalid output after Seek")
+	}
+
+	// block count of the cipher object is 1, so block 3 starts at offset 128
+	stream.SetCounter(3)
+	buffer := make([]byte, 100)
+	stream.XORKeyStream(buffer, plainText[128:228])
+	if !shared.AreByteSlicesEqual(buffer, expected[128:228]) {
+		t.Error("invalid output after SetCounter")
+	}
+}
+
+func Test_StreamCounterOverflow(t *testing.T) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 0xffffffff)
+	stream := cc.NewStream()
+
+	buffer := make([]byte, 64)
+	stream.XORKeyStream(buffer, buffer)
+
+	defer func() {
+		if recover() != ErrCounterOverflow {
+			t.Error("XORKeyStream doesn't panic with ErrCounterOverflow")
+		}
+	}()
+	stream.XORKeyStream(buffer[:1], buffer[:1])
+}
+
+// Test_StreamWholeBlocksOverflow checks if whole blocks up to
+// the end of the counter are written before the panic
+func Test_StreamWholeBlocksOverflow(t *testing.T) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 0xfffffffe)
+	expected := cc.Cipher(make([]byte, 2*blockSize))
+
+	buffer := make([]byte, 3*blockSize)
+	defer func() {
+		if recover() != ErrCounterOverflow {
+			t.Error("XORKeyStream doesn't panic with ErrCounterOverflow")
+		}
+		if !shared.AreByteSlicesEqual(buffer[:2*blockSize], expected) {
+			t.Error("invalid output before the counter overflow")
+		}
+	}()
+	cc.NewStream().XORKeyStream(buffer, buffer)
+}
+
+func Test_XORKeyStreamAtAllocs(t *testing.T) {
+	cc := newTestCipher()
+	buffer := make([]byte, 1000)
+	allocs := testing.AllocsPerRun(10, func() {
+		cc.XORKeyStreamAt(buffer, buffer, 10)
+	})
+	if allocs != 0 {
+		t.Errorf("XORKeyStreamAt allocates %v times, expected 0", allocs)
+	}
+}
+
+func BenchmarkXORKeyStreamAt(b *testing.B) {
+	cc := New(make([]byte, KeySize), make([]byte, NonceSize), 1)
+	buffer := make([]byte, 1<<20)
+	b.SetBytes(int64(len(buffer)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		cc.XORKeyStreamAt(buffer, buffer, 0)
+	}
+}
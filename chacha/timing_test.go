@@ -0,0 +1,56 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"ChaCha-Go/internal/dudect"
+)
+
+// Test_TimingDoubleRound checks that a double round takes
+// the same time for fixed (zero) and random states
+func Test_TimingDoubleRound(t *testing.T) {
+	dudect.SkipUnlessEnabled(t)
+	var state [16]uint32
+	result := dudect.Run(20000, 20, func(class int) {
+		for i := range state {
+			state[i] = 0
+			if class == dudect.Random {
+				state[i] = rand.Uint32()
+			}
+		}
+	}, func() {
+		x := state
+		doubleRounds(&x, 2)
+		dudect.Sink = x[0]^x[5]^x[10]^x[15] == 0
+	})
+	t.Log(result)
+	if result.Leaks() {
+		t.Errorf("timing depends on the data, %v", result)
+	}
+}
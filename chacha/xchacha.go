@@ -0,0 +1,91 @@
+/*
+Package chacha implements ChaCha20 algorithm
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha
+
+const (
+	HNonceSize = 16 // in bytes, HChaCha20 nonce
+	XNonceSize = 24 // in bytes, XChaCha20 nonce
+)
+
+// HChaCha20 derives 256-bit subkey from 256-bit key
+// and 128-bit nonce (draft-irtf-cfrg-xchacha, section 2.2).
+// It panics if the key or the nonce has invalid length.
+func HChaCha20(key, nonce []byte) []byte {
+	if len(key) != KeySize {
+		panic(KeySizeError(len(key)))
+	}
+	if len(nonce) != HNonceSize {
+		panic(NonceSizeError(len(nonce)))
+	}
+
+	var state [16]uint32
+	setConstants(&state)
+	for i := 0; i < 8; i++ {
+		state[i+4] = bytes2word(key[4*i:])
+	}
+	for i := 0; i < 4; i++ {
+		state[i+12] = bytes2word(nonce[4*i:])
+	}
+
+	// HChaCha20 skips the final addition of the initial state
+	doubleRounds(&state, 20)
+
+	subKey := make([]byte, KeySize)
+	for i := 0; i < 4; i++ {
+		word2bytes(subKey[4*i:], state[i])
+		word2bytes(subKey[16+4*i:], state[i+12])
+	}
+	return subKey
+}
+
+// NewXCipher creates new cipher object with 192-bit nonce.
+// First 16 bytes of the nonce derive subkey, remaining 8 bytes
+// prefixed with 4 zero bytes make ChaCha20 96-bit nonce.
+// It returns KeySizeError or NonceSizeError for invalid lengths.
+func NewXCipher(key, nonce []byte, blockCount uint32) (*ChaCha, error) {
+	if len(key) != KeySize {
+		return nil, KeySizeError(len(key))
+	}
+	if len(nonce) != XNonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+
+	subKey := HChaCha20(key, nonce[:HNonceSize])
+	chachaNonce := make([]byte, NonceSize)
+	copy(chachaNonce[4:], nonce[HNonceSize:])
+	return NewCipher(subKey, chachaNonce, blockCount)
+}
+
+// NewXChaCha20 creates new cipher object with 192-bit nonce.
+// It panics if the key or the nonce has invalid length, use NewXCipher
+// to get an error instead.
+func NewXChaCha20(key, nonce []byte, blockCount uint32) *ChaCha {
+	cc, err := NewXCipher(key, nonce, blockCount)
+	if err != nil {
+		panic(err)
+	}
+	return cc
+}
@@ -0,0 +1,205 @@
+/*
+Package chacha20poly1305 implements ChaCha20-Poly1305 AEAD (RFC 8439, section 2.8)
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha20poly1305
+
+import (
+	"crypto/cipher"
+	"errors"
+	"unsafe"
+
+	"ChaCha-Go/chacha"
+	"ChaCha-Go/poly1305"
+)
+
+const (
+	KeySize   = 32 // in bytes
+	NonceSize = 12 // in bytes
+	Overhead  = 16 // in bytes, size of Poly1305 tag
+)
+
+// maxPlaintextSize is the limit of the 32-bit block counter
+// starting from 1 (block 0 is used for the one-time key)
+const maxPlaintextSize uint64 = (1<<32 - 1) * 64
+
+var errOpen = errors.New("chacha20poly1305: message authentication failed")
+
+// ChaCha20Poly1305 AEAD object declaration
+type chacha20poly1305 struct {
+	key [KeySize]byte
+}
+
+// New creates ChaCha20-Poly1305 AEAD object
+// with passed 256-bit key
+func New(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("chacha20poly1305: bad key length")
+	}
+	c := new(chacha20poly1305)
+	copy(c.key[:], key)
+	return c, nil
+}
+
+func (c *chacha20poly1305) NonceSize() int {
+	return NonceSize
+}
+
+func (c *chacha20poly1305) Overhead() int {
+	return Overhead
+}
+
+// Seal encrypts and authenticates plaintext, authenticates
+// additional data and appends the result to dst
+func (c *chacha20poly1305) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != NonceSize {
+		panic("chacha20poly1305: bad nonce length passed to Seal")
+	}
+	if uint64(len(plaintext)) > maxPlaintextSize {
+		panic("chacha20poly1305: plaintext too large")
+	}
+	return seal(dst, c.key[:], nonce, plaintext, additionalData)
+}
+
+// Open authenticates and decrypts ciphertext, authenticates
+// additional data and, if successful, appends the plaintext to dst
+func (c *chacha20poly1305) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		panic("chacha20poly1305: bad nonce length passed to Open")
+	}
+	if len(ciphertext) < Overhead {
+		return nil, errOpen
+	}
+	if uint64(len(ciphertext)) > maxPlaintextSize+Overhead {
+		return nil, errOpen
+	}
+	return open(dst, c.key[:], nonce, ciphertext, additionalData)
+}
+
+// seal writes the ciphertext and the tag directly to the space appended
+// to dst. It panics if the output overlaps plaintext other than in place.
+func seal(dst, key, nonce, plaintext, additionalData []byte) []byte {
+	ret, out := sliceForAppend(dst, len(plaintext)+Overhead)
+	if inexactOverlap(out, plaintext) {
+		panic("chacha20poly1305: invalid buffer overlap")
+	}
+	ciphertext, tag := out[:len(plaintext)], out[len(plaintext):]
+
+	polyKey := oneTimeKey(key, nonce)
+	chacha.New(key, nonce, 1).XORKeyStreamAt(ciphertext, plaintext, 0)
+	macData(polyKey, additionalData, ciphertext).Sum(tag[:0])
+	return ret
+}
+
+// open verifies the tag before anything is written
+// and decrypts directly to the space appended to dst
+func open(dst, key, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	tag := ciphertext[len(ciphertext)-Overhead:]
+	ciphertext = ciphertext[:len(ciphertext)-Overhead]
+
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if inexactOverlap(out, ciphertext) {
+		panic("chacha20poly1305: invalid buffer overlap")
+	}
+
+	polyKey := oneTimeKey(key, nonce)
+	if !verifyTag(polyKey, additionalData, ciphertext, tag) {
+		return nil, errOpen
+	}
+	chacha.New(key, nonce, 1).XORKeyStreamAt(out, ciphertext, 0)
+	return ret, nil
+}
+
+// oneTimeKey generates Poly1305 key
+// from the first ChaCha20 block (RFC 8439, section 2.6)
+func oneTimeKey(key, nonce []byte) []byte {
+	polyKey := make([]byte, poly1305.KeySize)
+	chacha.New(key, nonce, 0).XORKeyStreamAt(polyKey, polyKey, 0)
+	return polyKey
+}
+
+// macData feeds the MAC with data in order
+// described in RFC 8439, section 2.8
+func macData(polyKey, additionalData, ciphertext []byte) *poly1305.MAC {
+	var padding [16]byte
+
+	m := poly1305.New(polyKey)
+	m.Write(additionalData)
+	m.Write(padding[:pad16(len(additionalData))])
+	m.Write(ciphertext)
+	m.Write(padding[:pad16(len(ciphertext))])
+
+	var lengths [16]byte
+	putUint64(lengths[0:], uint64(len(additionalData)))
+	putUint64(lengths[8:], uint64(len(ciphertext)))
+	m.Write(lengths[:])
+	return m
+}
+
+func verifyTag(polyKey, additionalData, ciphertext, tag []byte) bool {
+	return macData(polyKey, additionalData, ciphertext).Verify(tag)
+}
+
+func pad16(n int) int {
+	if n%16 == 0 {
+		return 0
+	}
+	return 16 - n%16
+}
+
+func putUint64(out []byte, v uint64) {
+	for i := 0; i < 8; i++ {
+		out[i] = byte(v >> (8 * i))
+	}
+}
+
+// sliceForAppend extends passed slice by n bytes. It returns
+// the extended slice and the slice with the appended part.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
+
+// anyOverlap reports whether x and y share memory at any index
+func anyOverlap(x, y []byte) bool {
+	return len(x) > 0 && len(y) > 0 &&
+		uintptr(unsafe.Pointer(&x[0])) <= uintptr(unsafe.Pointer(&y[len(y)-1])) &&
+		uintptr(unsafe.Pointer(&y[0])) <= uintptr(unsafe.Pointer(&x[len(x)-1]))
+}
+
+// inexactOverlap reports whether x and y share memory at any
+// non-corresponding index, in-place encryption (x and y start
+// at the same address) is allowed
+func inexactOverlap(x, y []byte) bool {
+	if len(x) == 0 || len(y) == 0 || &x[0] == &y[0] {
+		return false
+	}
+	return anyOverlap(x, y)
+}
@@ -0,0 +1,291 @@
+/*
+Package chacha20poly1305 implements ChaCha20-Poly1305 AEAD (RFC 8439, section 2.8)
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha20poly1305
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+func fromHex(s string) []byte {
+	data, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// interface check
+var (
+	_ cipher.AEAD = (*chacha20poly1305)(nil)
+	_ cipher.AEAD = (*xchacha20poly1305)(nil)
+)
+
+func Test_oneTimeKey(t *testing.T) {
+	vectors := []struct {
+		name  string
+		key   []byte
+		nonce []byte
+		out   []byte
+	}{
+		{
+			name:  "RFC 8439 2.6.2",
+			key:   fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f"),
+			nonce: fromHex("000000000001020304050607"),
+			out:   fromHex("8ad5a08b905f81cc815040274ab29471a833b637e3fd0da508dbb8e2fdd1a646"),
+		},
+		{
+			name:  "RFC 8439 A.4 #1",
+			key:   make([]byte, 32),
+			nonce: make([]byte, 12),
+			out:   fromHex("76b8e0ada0f13d90405d6ae55386bd28bdd219b8a08ded1aa836efcc8b770dc7"),
+		},
+		{
+			name:  "RFC 8439 A.4 #2",
+			key:   fromHex("0000000000000000000000000000000000000000000000000000000000000001"),
+			nonce: fromHex("000000000000000000000002"),
+			out:   fromHex("ecfa254f845f647473d3cb140da9e87606cb33066c447b87bc2666dde3fbb739"),
+		},
+		{
+			name:  "RFC 8439 A.4 #3",
+			key:   fromHex("1c9240a5eb55d38af333888604f6b5f0473917c1402b80099dca5cbc207075c0"),
+			nonce: fromHex("000000000000000000000002"),
+			out:   fromHex("965e3bc6f9ec7ed9560808f4d229f94b137ff275ca9b3fcbdd59deaad23310ae"),
+		},
+	}
+
+	for _, v := range vectors {
+		if !shared.AreByteSlicesEqual(oneTimeKey(v.key, v.nonce), v.out) {
+			t.Errorf("%s: invalid one-time key", v.name)
+		}
+	}
+}
+
+func Test_Seal(t *testing.T) {
+	key := fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+	nonce := fromHex("070000004041424344454647")
+	aad := fromHex("50515253c0c1c2c3c4c5c6c7")
+	plainText := "Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it."
+	expected := fromHex(
+		"d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d6" +
+			"3dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b36" +
+			"92ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc" +
+			"3ff4def08e4b7a9de576d26586cec64b6116" +
+			"1ae10b594f09e26a7e902ecbd0600691")
+
+	aead, err := New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := aead.Seal(nil, nonce, []byte(plainText), aad)
+	if !shared.AreByteSlicesEqual(sealed, expected) {
+		t.Error("plain text -> sealed text failed (RFC 8439 2.8.2)")
+	}
+
+	opened, err := aead.Open(nil, nonce, sealed, aad)
+	if err != nil || string(opened) != plainText {
+		t.Error("sealed text -> plain text failed (RFC 8439 2.8.2)")
+	}
+}
+
+func Test_Open(t *testing.T) {
+	key := fromHex("1c9240a5eb55d38af333888604f6b5f0473917c1402b80099dca5cbc207075c0")
+	nonce := fromHex("000000000102030405060708")
+	aad := fromHex("f33388860000000000004e91")
+	sealed := fromHex(
+		"64a0861575861af460f062c79be643bd5e805cfd345cf389f108670ac76c8cb2" +
+			"4c6cfc18755d43eea09ee94e382d26b0bdb7b73c321b0100d4f03b7f355894cf" +
+			"332f830e710b97ce98c8a84abd0b948114ad176e008d33bd60f982b1ff37c855" +
+			"9797a06ef4f0ef61c186324e2b3506383606907b6a7c02b0f9f6157b53c867e4" +
+			"b9166c767b804d46a59b5216cde7a4e99040c5a40433225ee282a1b0a06c523e" +
+			"af4534d7f83fa1155b0047718cbc546a0d072b04b3564eea1b422273f548271a" +
+			"0bb2316053fa76991955ebd63159434ecebb4e466dae5a1073a6727627097a10" +
+			"49e617d91d361094fa68f0ff77987130305beaba2eda04df997b714d6c6f2c29" +
+			"a6ad5cb4022b02709b" +
+			"eead9d67890cbb22392336fea1851f38")
+	plainText := "Internet-Drafts are draft documents valid for a maximum of six months and may be updated, replaced, or obsoleted by other documents at any time. It is inappropriate to use Internet-Drafts as reference material or to cite them other than as /“work in progress./”"
+
+	aead, err := New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := aead.Open(nil, nonce, sealed, aad)
+	if err != nil || string(opened) != plainText {
+		t.Error("sealed text -> plain text failed (RFC 8439 A.5)")
+	}
+
+	// every single bit flip must be detected
+	for i := range sealed {
+		sealed[i] ^= 0x01
+		if _, err := aead.Open(nil, nonce, sealed, aad); err == nil {
+			t.Errorf("modified byte %d accepted", i)
+		}
+		sealed[i] ^= 0x01
+	}
+	aad[0] ^= 0x01
+	if _, err := aead.Open(nil, nonce, sealed, aad); err == nil {
+		t.Error("modified additional data accepted")
+	}
+	aad[0] ^= 0x01
+	if _, err := aead.Open(nil, nonce, sealed[:len(sealed)-1], aad); err == nil {
+		t.Error("truncated text accepted")
+	}
+	if _, err := aead.Open(nil, nonce, sealed[:Overhead-1], aad); err == nil {
+		t.Error("too short text accepted")
+	}
+}
+
+// Test_SealOpen checks round trip for lengths around
+// the block boundaries and appending to dst
+func Test_SealOpen(t *testing.T) {
+	key := make([]byte, KeySize)
+	nonce := make([]byte, NonceSize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	aead, _ := New(key)
+
+	for n := 0; n <= 200; n++ {
+		plainText := make([]byte, n)
+		for i := range plainText {
+			plainText[i] = byte(i * 7)
+		}
+		aad := plainText[:n/3]
+
+		prefix := []byte{1, 2, 3}
+		sealed := aead.Seal(prefix, nonce, plainText, aad)
+		if len(sealed) != len(prefix)+n+Overhead || !shared.AreByteSlicesEqual(sealed[:3], prefix) {
+			t.Fatalf("invalid sealed text for length %d", n)
+		}
+		opened, err := aead.Open(nil, nonce, sealed[3:], aad)
+		if err != nil || !shared.AreByteSlicesEqual(opened, plainText) {
+			t.Fatalf("round trip failed for length %d", n)
+		}
+
+		// in-place
+		buffer := make([]byte, n, n+Overhead)
+		copy(buffer, plainText)
+		sealed = aead.Seal(buffer[:0], nonce, buffer, aad)
+		opened, err = aead.Open(sealed[:0], nonce, sealed, aad)
+		if err != nil || !shared.AreByteSlicesEqual(opened, plainText) {
+			t.Fatalf("in-place round trip failed for length %d", n)
+		}
+	}
+}
+
+// Test_InvalidOverlap checks that output overlapping
+// the input other than in place is rejected
+func Test_InvalidOverlap(t *testing.T) {
+	aead, _ := New(make([]byte, KeySize))
+	nonce := make([]byte, NonceSize)
+	buffer := make([]byte, 100+2*Overhead)
+
+	mustPanic := func(name string, f func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: overlapping buffers accepted", name)
+			}
+		}()
+		f()
+	}
+	mustPanic("seal", func() { aead.Seal(buffer[1:1], nonce, buffer[:100], nil) })
+	sealed := aead.Seal(buffer[:0], nonce, buffer[:100], nil)
+	mustPanic("open", func() { aead.Open(buffer[1:1], nonce, sealed, nil) })
+}
+
+func Test_XSealOpen(t *testing.T) {
+	key := fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+	nonce := fromHex("404142434445464748494a4b4c4d4e4f5051525354555657")
+	aad := fromHex("50515253c0c1c2c3c4c5c6c7")
+	plainText := "Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it."
+	expected := fromHex(
+		"bd6d179d3e83d43b9576579493c0e939572a1700252bfaccbed2902c21396cbb" +
+			"731c7f1b0b4aa6440bf3a82f4eda7e39ae64c6708c54c216cb96b72e1213b452" +
+			"2f8c9ba40db5d945b11b69b982c1bb9e3f3fac2bc369488f76b2383565d3fff9" +
+			"21f9664c97637da9768812f615c68b13b52e" +
+			"c0875924c1c7987947deafd8780acf49")
+
+	aead, err := NewX(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := aead.Seal(nil, nonce, []byte(plainText), aad)
+	if !shared.AreByteSlicesEqual(sealed, expected) {
+		t.Error("plain text -> sealed text failed (draft-irtf-cfrg-xchacha A.3.1)")
+	}
+
+	opened, err := aead.Open(nil, nonce, sealed, aad)
+	if err != nil || string(opened) != plainText {
+		t.Error("sealed text -> plain text failed (draft-irtf-cfrg-xchacha A.3.1)")
+	}
+
+	sealed[0] ^= 0x01
+	if _, err := aead.Open(nil, nonce, sealed, aad); err == nil {
+		t.Error("modified text accepted")
+	}
+}
+
+func Test_New(t *testing.T) {
+	if _, err := New(make([]byte, KeySize-1)); err == nil {
+		t.Error("short key accepted")
+	}
+	aead, err := New(make([]byte, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aead.NonceSize() != NonceSize || aead.Overhead() != Overhead {
+		t.Error("invalid sizes")
+	}
+
+	if _, err := NewX(make([]byte, KeySize+1)); err == nil {
+		t.Error("long key accepted")
+	}
+	aead, err = NewX(make([]byte, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aead.NonceSize() != NonceSizeX || aead.Overhead() != Overhead {
+		t.Error("invalid sizes")
+	}
+}
+
+func BenchmarkSeal(b *testing.B) {
+	key := make([]byte, KeySize)
+	nonce := make([]byte, NonceSize)
+	plainText := make([]byte, 8*1024)
+	aead, _ := New(key)
+
+	b.SetBytes(int64(len(plainText)))
+	b.ReportAllocs()
+	buffer := make([]byte, 0, len(plainText)+Overhead)
+	for n := 0; n < b.N; n++ {
+		aead.Seal(buffer, nonce, plainText, nil)
+	}
+}
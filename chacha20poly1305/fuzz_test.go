@@ -0,0 +1,88 @@
+/*
+Package chacha20poly1305 implements ChaCha20-Poly1305 AEAD (RFC 8439, section 2.8)
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha20poly1305
+
+import (
+	"crypto/cipher"
+	"testing"
+
+	reference "golang.org/x/crypto/chacha20poly1305"
+
+	"ChaCha-Go/shared"
+)
+
+// Differential tests against golang.org/x/crypto/chacha20poly1305.
+// Run a fuzz target with e.g. go test -fuzz FuzzAEAD ./chacha20poly1305
+
+func FuzzAEAD(f *testing.F) {
+	addAEADSeeds(f, NonceSize)
+	f.Fuzz(func(t *testing.T, key, nonce, aad, plaintext []byte, flip uint16) {
+		key, nonce = shared.FitBytes(key, KeySize), shared.FitBytes(nonce, NonceSize)
+		ours, _ := New(key)
+		theirs, _ := reference.New(key)
+		compareAEAD(t, ours, theirs, nonce, aad, plaintext, int(flip))
+	})
+}
+
+func FuzzXAEAD(f *testing.F) {
+	addAEADSeeds(f, NonceSizeX)
+	f.Fuzz(func(t *testing.T, key, nonce, aad, plaintext []byte, flip uint16) {
+		key, nonce = shared.FitBytes(key, KeySize), shared.FitBytes(nonce, NonceSizeX)
+		ours, _ := NewX(key)
+		theirs, _ := reference.NewX(key)
+		compareAEAD(t, ours, theirs, nonce, aad, plaintext, int(flip))
+	})
+}
+
+func addAEADSeeds(f *testing.F, nonceSize int) {
+	for _, n := range []int{0, 1, 15, 16, 17, 63, 64, 65, 300} {
+		f.Add(make([]byte, KeySize), make([]byte, nonceSize), []byte("header"), make([]byte, n), uint16(n))
+	}
+}
+
+// compareAEAD checks that both implementations seal to the same output,
+// open each other's output and reject it with the bit flip number flip
+func compareAEAD(t *testing.T, ours, theirs cipher.AEAD, nonce, aad, plaintext []byte, flip int) {
+	sealed := ours.Seal(nil, nonce, plaintext, aad)
+	if !shared.AreByteSlicesEqual(sealed, theirs.Seal(nil, nonce, plaintext, aad)) {
+		t.Fatalf("%d bytes, %d bytes of additional data: output differs from x/crypto", len(plaintext), len(aad))
+	}
+	opened, err := ours.Open(nil, nonce, sealed, aad)
+	if err != nil || !shared.AreByteSlicesEqual(opened, plaintext) {
+		t.Fatalf("%d bytes: Open failed: %v", len(plaintext), err)
+	}
+
+	// a single bit flipped in the additional data or the sealed text
+	modified := append(append([]byte(nil), aad...), sealed...)
+	flip %= 8 * len(modified)
+	modified[flip/8] ^= 1 << (flip % 8)
+	aad, sealed = modified[:len(aad)], modified[len(aad):]
+	_, errOurs := ours.Open(nil, nonce, sealed, aad)
+	_, errTheirs := theirs.Open(nil, nonce, sealed, aad)
+	if errOurs == nil || errTheirs == nil {
+		t.Fatalf("bit %d flipped: accepted (ours %v, x/crypto %v)", flip, errOurs, errTheirs)
+	}
+}
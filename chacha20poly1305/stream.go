@@ -0,0 +1,261 @@
+/*
+Package chacha20poly1305 implements ChaCha20-Poly1305 AEAD (RFC 8439, section 2.8)
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha20poly1305
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"ChaCha-Go/chacha"
+)
+
+// Streaming encryption splits the plaintext into chunks sealed separately
+// (the STREAM construction), so a reader can verify and return data
+// before the end of the stream. The format:
+//
+//	salt       16 bytes  random, the stream key is HChaCha20(key, salt)
+//	chunk 0    StreamChunkSize+Overhead bytes
+//	...
+//	chunk n    0..StreamChunkSize+Overhead bytes, the last chunk
+//
+// Every chunk is the plaintext sealed with ChaCha20-Poly1305 under
+// the stream key, the nonce is the chunk number (11 bytes, big endian)
+// followed by a flag byte, 1 for the last chunk and 0 for the others:
+//
+//	nonce = counter[11] || last[1]
+//
+// All chunks but the last carry exactly StreamChunkSize bytes of plaintext.
+// The last chunk carries 1..StreamChunkSize bytes, it is empty only if the
+// plaintext is empty. Additional data passed to the writer and the reader
+// is authenticated with every chunk.
+//
+// Modified, reordered, removed or appended chunks fail authentication,
+// because the chunk number and the flag are part of the nonce. A stream
+// truncated at a chunk boundary fails as well, its last chunk was sealed
+// without the flag. The random salt makes the stream key unique, so the
+// same key can be used for many streams.
+
+const (
+	StreamChunkSize  = 64 * 1024 // in bytes, plaintext of a full chunk
+	StreamSaltSize   = 16        // in bytes
+	streamSealedSize = StreamChunkSize + Overhead
+)
+
+var errStreamClosed = errors.New("chacha20poly1305: write to closed stream")
+
+// streamNonce returns nonce of the chunk
+func streamNonce(nonce *[NonceSize]byte, counter uint64, last bool) {
+	*nonce = [NonceSize]byte{}
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+}
+
+// newStreamAEAD returns the AEAD object with the stream key
+func newStreamAEAD(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("chacha20poly1305: bad key length")
+	}
+	return New(chacha.HChaCha20(key, salt))
+}
+
+// encryptWriter is the stream writer returned by NewEncryptWriter
+type encryptWriter struct {
+	w              io.Writer
+	aead           cipher.AEAD
+	additionalData []byte
+	counter        uint64
+	nonce          [NonceSize]byte
+	buffer         []byte // plaintext of the current chunk, sealed in place
+	err            error
+}
+
+// NewEncryptWriter returns a writer which encrypts data written to it
+// and writes the stream (see StreamChunkSize for the format) to w.
+// The salt is written immediately. Close must be called to seal
+// the last chunk, it doesn't close w.
+func NewEncryptWriter(w io.Writer, key, additionalData []byte) (io.WriteCloser, error) {
+	salt := make([]byte, StreamSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:              w,
+		aead:           aead,
+		additionalData: append([]byte(nil), additionalData...),
+		buffer:         make([]byte, 0, streamSealedSize),
+	}, nil
+}
+
+// Write encrypts p. A full chunk is sealed only when more data
+// follows, because the last chunk may be a full one.
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	written := 0
+	for len(p) > 0 {
+		if len(ew.buffer) == StreamChunkSize {
+			if err := ew.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(ew.buffer[len(ew.buffer):StreamChunkSize], p)
+		ew.buffer = ew.buffer[:len(ew.buffer)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last chunk and writes it
+func (ew *encryptWriter) Close() error {
+	if ew.err != nil {
+		if ew.err == errStreamClosed {
+			return nil
+		}
+		return ew.err
+	}
+	if err := ew.flush(true); err != nil {
+		return err
+	}
+	ew.err = errStreamClosed
+	return nil
+}
+
+// flush seals the buffered chunk and writes it
+func (ew *encryptWriter) flush(last bool) error {
+	streamNonce(&ew.nonce, ew.counter, last)
+	sealed := ew.aead.Seal(ew.buffer[:0], ew.nonce[:], ew.buffer, ew.additionalData)
+	if _, err := ew.w.Write(sealed); err != nil {
+		ew.err = err
+		return err
+	}
+	ew.counter++
+	ew.buffer = ew.buffer[:0]
+	return nil
+}
+
+// decryptReader is the stream reader returned by NewDecryptReader
+type decryptReader struct {
+	r              *bufio.Reader
+	aead           cipher.AEAD
+	additionalData []byte
+	counter        uint64
+	nonce          [NonceSize]byte
+	buffer         []byte
+	plaintext      []byte // not returned part of the current chunk
+	last           bool
+	err            error
+}
+
+// NewDecryptReader returns a reader which decrypts the stream written
+// by NewEncryptWriter. The salt is read immediately. Read returns
+// plaintext of a chunk only after the chunk is authenticated and an
+// error if the stream was modified or truncated, io.EOF is returned only
+// after the last chunk. Data returned before an error must be discarded
+// by callers which need the whole stream to be authentic.
+func NewDecryptReader(r io.Reader, key, additionalData []byte) (io.Reader, error) {
+	salt := make([]byte, StreamSaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:              bufio.NewReader(r),
+		aead:           aead,
+		additionalData: append([]byte(nil), additionalData...),
+		buffer:         make([]byte, streamSealedSize),
+	}, nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.plaintext) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.last {
+			return 0, io.EOF
+		}
+		dr.err = dr.readChunk()
+	}
+	n := copy(p, dr.plaintext)
+	dr.plaintext = dr.plaintext[n:]
+	return n, nil
+}
+
+// readChunk reads and opens the next chunk. The chunk is
+// the last one if it's shorter than a full chunk or if no
+// data follows it.
+func (dr *decryptReader) readChunk() error {
+	n, err := io.ReadFull(dr.r, dr.buffer)
+	switch err {
+	case nil:
+		if _, err := dr.r.Peek(1); err == io.EOF {
+			dr.last = true
+		} else if err != nil {
+			return err
+		}
+	case io.ErrUnexpectedEOF, io.EOF:
+		dr.last = true
+	default:
+		return err
+	}
+	if n < Overhead {
+		return errOpen
+	}
+
+	streamNonce(&dr.nonce, dr.counter, dr.last)
+	plaintext, err := dr.aead.Open(dr.buffer[:0], dr.nonce[:], dr.buffer[:n], dr.additionalData)
+	if err != nil {
+		return err
+	}
+	if len(plaintext) == 0 && dr.counter > 0 {
+		// not written by NewEncryptWriter
+		return errOpen
+	}
+	dr.counter++
+	dr.plaintext = plaintext
+	return nil
+}
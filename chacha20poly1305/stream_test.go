@@ -0,0 +1,205 @@
+/*
+Package chacha20poly1305 implements ChaCha20-Poly1305 AEAD (RFC 8439, section 2.8)
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha20poly1305
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+var streamKey = fromHex("808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f")
+
+func streamPlainText(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	return data
+}
+
+// encryptStream encrypts plaintext written in pieces of passed size
+func encryptStream(t *testing.T, plaintext, additionalData []byte, piece int) []byte {
+	var out bytes.Buffer
+	w, err := NewEncryptWriter(&out, streamKey, additionalData)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter returned %v", err)
+	}
+	for len(plaintext) > 0 {
+		n := min(piece, len(plaintext))
+		if _, err := w.Write(plaintext[:n]); err != nil {
+			t.Fatalf("Write returned %v", err)
+		}
+		plaintext = plaintext[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	return out.Bytes()
+}
+
+func decryptStream(stream, additionalData []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(stream), streamKey, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func Test_StreamRoundTrip(t *testing.T) {
+	sizes := []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3 * StreamChunkSize, 3*StreamChunkSize + 100}
+	for _, n := range sizes {
+		for _, piece := range []int{1000, StreamChunkSize, 5 * StreamChunkSize} {
+			plaintext := streamPlainText(n)
+			stream := encryptStream(t, plaintext, []byte("header"), piece)
+
+			chunks := max(1, (n+StreamChunkSize-1)/StreamChunkSize)
+			if len(stream) != StreamSaltSize+n+chunks*Overhead {
+				t.Errorf("%d bytes: invalid stream size %d", n, len(stream))
+			}
+			decrypted, err := decryptStream(stream, []byte("header"))
+			if err != nil {
+				t.Fatalf("%d bytes, pieces of %d: %v", n, piece, err)
+			}
+			if !shared.AreByteSlicesEqual(decrypted, plaintext) {
+				t.Errorf("%d bytes, pieces of %d: invalid round trip", n, piece)
+			}
+		}
+	}
+}
+
+// Test_StreamChunk checks the format against the AEAD with
+// the stream key and the nonce made of the counter and the flag
+func Test_StreamChunk(t *testing.T) {
+	plaintext := streamPlainText(StreamChunkSize + 10)
+	stream := encryptStream(t, plaintext, nil, len(plaintext))
+
+	aead, _ := newStreamAEAD(streamKey, stream[:StreamSaltSize])
+	chunk0 := stream[StreamSaltSize : StreamSaltSize+streamSealedSize]
+	chunk1 := stream[StreamSaltSize+streamSealedSize:]
+
+	nonce := make([]byte, NonceSize)
+	if out, err := aead.Open(nil, nonce, chunk0, nil); err != nil || !shared.AreByteSlicesEqual(out, plaintext[:StreamChunkSize]) {
+		t.Errorf("chunk 0 failed: %v", err)
+	}
+	nonce[10], nonce[11] = 1, 1
+	if out, err := aead.Open(nil, nonce, chunk1, nil); err != nil || !shared.AreByteSlicesEqual(out, plaintext[StreamChunkSize:]) {
+		t.Errorf("last chunk 1 failed: %v", err)
+	}
+}
+
+func Test_StreamModified(t *testing.T) {
+	plaintext := streamPlainText(3 * StreamChunkSize)
+	stream := encryptStream(t, plaintext, []byte("header"), len(plaintext))
+	chunk := func(i int) []byte {
+		start := StreamSaltSize + i*streamSealedSize
+		return stream[start : start+streamSealedSize]
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	flipped := append([]byte(nil), stream...)
+	flipped[StreamSaltSize+streamSealedSize+100] ^= 0x01
+
+	cases := []struct {
+		name   string
+		stream []byte
+	}{
+		{"truncated at chunk boundary", stream[:StreamSaltSize+2*streamSealedSize]},
+		{"truncated inside chunk", stream[:len(stream)-1]},
+		{"truncated after salt", stream[:StreamSaltSize]},
+		{"reordered chunks", concat(stream[:StreamSaltSize], chunk(1), chunk(0), chunk(2))},
+		{"removed chunk", concat(stream[:StreamSaltSize], chunk(0), chunk(2))},
+		{"duplicated chunk", concat(stream[:StreamSaltSize], chunk(0), chunk(0), chunk(1), chunk(2))},
+		{"appended data", concat(stream, []byte{0})},
+		{"appended empty last chunk", concat(stream, encryptStream(t, nil, []byte("header"), 1)[StreamSaltSize:])},
+		{"modified byte", flipped},
+		{"modified salt", concat([]byte{stream[0] ^ 1}, stream[1:])},
+	}
+	for _, c := range cases {
+		if _, err := decryptStream(c.stream, []byte("header")); err == nil {
+			t.Errorf("%s: not detected", c.name)
+		}
+	}
+	if _, err := decryptStream(stream, []byte("other header")); err == nil {
+		t.Error("modified additional data not detected")
+	}
+	if _, err := decryptStream(stream[:StreamSaltSize-1], []byte("header")); err != io.ErrUnexpectedEOF {
+		t.Errorf("short salt: expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+// Test_StreamEarlyData checks that authenticated chunks
+// are returned before a modified chunk is detected
+func Test_StreamEarlyData(t *testing.T) {
+	plaintext := streamPlainText(2 * StreamChunkSize)
+	stream := encryptStream(t, plaintext, nil, len(plaintext))
+	stream[len(stream)-1] ^= 0x01
+
+	r, _ := NewDecryptReader(bytes.NewReader(stream), streamKey, nil)
+	first := make([]byte, StreamChunkSize)
+	if _, err := io.ReadFull(r, first); err != nil || !shared.AreByteSlicesEqual(first, plaintext[:StreamChunkSize]) {
+		t.Errorf("first chunk failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := r.Read(first); n != 0 || err != errOpen {
+			t.Errorf("expected authentication error, got %d, %v", n, err)
+		}
+	}
+}
+
+func Test_StreamWriterErrors(t *testing.T) {
+	if _, err := NewEncryptWriter(io.Discard, make([]byte, 16), nil); err == nil {
+		t.Error("bad key length accepted by writer")
+	}
+	if _, err := NewDecryptReader(bytes.NewReader(make([]byte, StreamSaltSize)), make([]byte, 16), nil); err == nil {
+		t.Error("bad key length accepted by reader")
+	}
+
+	w, _ := NewEncryptWriter(io.Discard, streamKey, nil)
+	w.Write([]byte("data"))
+	if err := w.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close returned %v", err)
+	}
+	if _, err := w.Write([]byte("more")); err != errStreamClosed {
+		t.Errorf("write after Close: expected errStreamClosed, got %v", err)
+	}
+}
+
+func BenchmarkStream(b *testing.B) {
+	plaintext := streamPlainText(1024 * 1024)
+	b.SetBytes(int64(len(plaintext)))
+	for i := 0; i < b.N; i++ {
+		w, _ := NewEncryptWriter(io.Discard, streamKey, nil)
+		w.Write(plaintext)
+		w.Close()
+	}
+}
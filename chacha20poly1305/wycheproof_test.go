@@ -0,0 +1,179 @@
+/*
+Package chacha20poly1305 implements ChaCha20-Poly1305 AEAD (RFC 8439, section 2.8)
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha20poly1305
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+// wycheproofFile is the AEAD file format of Project Wycheproof
+// (schema aead_test_schema_v1.json)
+type wycheproofFile struct {
+	Algorithm  string `json:"algorithm"`
+	TestGroups []struct {
+		IVSize  int              `json:"ivSize"`
+		KeySize int              `json:"keySize"`
+		TagSize int              `json:"tagSize"`
+		Tests   []wycheproofTest `json:"tests"`
+	} `json:"testGroups"`
+}
+
+type wycheproofTest struct {
+	ID      int      `json:"tcId"`
+	Comment string   `json:"comment"`
+	Flags   []string `json:"flags"`
+	Key     string   `json:"key"`
+	IV      string   `json:"iv"`
+	AAD     string   `json:"aad"`
+	Msg     string   `json:"msg"`
+	CT      string   `json:"ct"`
+	Tag     string   `json:"tag"`
+	Result  string   `json:"result"` // valid, invalid or acceptable
+}
+
+// wycheproofFlags are the flags the runner knows, a new flag
+// fails the test until it's checked how it should be handled
+var wycheproofFlags = []string{
+	"Ktv", "Pseudorandom", "ModifiedTag", "InvalidNonceSize",
+	"EdgeCaseCiphertext", "EdgeCasePoly1305", "EdgeCasePolyKey", "EdgeCaseTag",
+}
+
+func Test_WycheproofChaCha20Poly1305(t *testing.T) {
+	runWycheproof(t, "chacha20_poly1305_test.json", "CHACHA20-POLY1305", New)
+}
+
+func Test_WycheproofXChaCha20Poly1305(t *testing.T) {
+	runWycheproof(t, "xchacha20_poly1305_test.json", "XCHACHA20-POLY1305", NewX)
+}
+
+func runWycheproof(t *testing.T, name, algorithm string, newAEAD func([]byte) (cipher.AEAD, error)) {
+	data, err := os.ReadFile(filepath.Join("testdata", "wycheproof", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file wycheproofFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Algorithm != algorithm {
+		t.Fatalf("%s: unexpected algorithm %s", name, file.Algorithm)
+	}
+
+	tests := 0
+	for _, group := range file.TestGroups {
+		for _, test := range group.Tests {
+			tests++
+			for _, flag := range test.Flags {
+				if !slices.Contains(wycheproofFlags, flag) {
+					t.Errorf("test %d: unknown flag %s", test.ID, flag)
+				}
+			}
+			aead, err := newAEAD(wycheproofHex(test.Key))
+			if err != nil {
+				t.Errorf("test %d: %v", test.ID, err)
+				continue
+			}
+			checkWycheproof(t, aead, &test)
+		}
+	}
+	if tests == 0 {
+		t.Errorf("%s: no tests", name)
+	}
+}
+
+// checkWycheproof checks a single vector. Valid vectors must
+// seal to the ciphertext and open, invalid ones must be rejected,
+// for acceptable ones both outcomes are fine. Nonce of invalid size
+// must be rejected with panic, like by other cipher.AEAD implementations.
+func checkWycheproof(t *testing.T, aead cipher.AEAD, test *wycheproofTest) {
+	nonce := wycheproofHex(test.IV)
+	aad := wycheproofHex(test.AAD)
+	msg := wycheproofHex(test.Msg)
+	sealed := append(wycheproofHex(test.CT), wycheproofHex(test.Tag)...)
+
+	if len(nonce) != aead.NonceSize() {
+		if test.Result == "valid" {
+			t.Errorf("test %d: valid vector with nonce of %d bytes", test.ID, len(nonce))
+		}
+		if !panics(func() { aead.Seal(nil, nonce, msg, aad) }) {
+			t.Errorf("test %d: Seal accepted nonce of %d bytes", test.ID, len(nonce))
+		}
+		if !panics(func() { aead.Open(nil, nonce, sealed, aad) }) {
+			t.Errorf("test %d: Open accepted nonce of %d bytes", test.ID, len(nonce))
+		}
+		return
+	}
+	if slices.Contains(test.Flags, "InvalidNonceSize") {
+		t.Errorf("test %d: InvalidNonceSize with nonce of %d bytes", test.ID, len(nonce))
+	}
+
+	opened, err := aead.Open(nil, nonce, sealed, aad)
+	switch test.Result {
+	case "valid":
+		if err != nil || !shared.AreByteSlicesEqual(opened, msg) {
+			t.Errorf("test %d (%s): Open failed: %v", test.ID, test.Comment, err)
+		}
+		if !shared.AreByteSlicesEqual(aead.Seal(nil, nonce, msg, aad), sealed) {
+			t.Errorf("test %d (%s): Seal failed", test.ID, test.Comment)
+		}
+	case "invalid":
+		if err == nil {
+			t.Errorf("test %d (%s): invalid ciphertext accepted", test.ID, test.Comment)
+		}
+	case "acceptable":
+		if err == nil && !shared.AreByteSlicesEqual(opened, msg) {
+			t.Errorf("test %d (%s): Open returned wrong plaintext", test.ID, test.Comment)
+		}
+	default:
+		t.Errorf("test %d: unknown result %q", test.ID, test.Result)
+	}
+}
+
+func panics(f func()) (panicked bool) {
+	defer func() {
+		if recover() != nil {
+			panicked = true
+		}
+	}()
+	f()
+	return false
+}
+
+func wycheproofHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,98 @@
+/*
+Package chacha20poly1305 implements ChaCha20-Poly1305 AEAD (RFC 8439, section 2.8)
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package chacha20poly1305
+
+import (
+	"crypto/cipher"
+	"errors"
+
+	"ChaCha-Go/chacha"
+)
+
+// NonceSizeX is the size of XChaCha20-Poly1305 nonce, in bytes.
+// It is big enough to be generated randomly for every message.
+const NonceSizeX = 24
+
+// XChaCha20Poly1305 AEAD object declaration
+type xchacha20poly1305 struct {
+	key [KeySize]byte
+}
+
+// NewX creates XChaCha20-Poly1305 AEAD object
+// with passed 256-bit key (draft-irtf-cfrg-xchacha, section 2.3)
+func NewX(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("chacha20poly1305: bad key length")
+	}
+	c := new(xchacha20poly1305)
+	copy(c.key[:], key)
+	return c, nil
+}
+
+func (c *xchacha20poly1305) NonceSize() int {
+	return NonceSizeX
+}
+
+func (c *xchacha20poly1305) Overhead() int {
+	return Overhead
+}
+
+// Seal encrypts and authenticates plaintext, authenticates
+// additional data and appends the result to dst
+func (c *xchacha20poly1305) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != NonceSizeX {
+		panic("chacha20poly1305: bad nonce length passed to Seal")
+	}
+	if uint64(len(plaintext)) > maxPlaintextSize {
+		panic("chacha20poly1305: plaintext too large")
+	}
+	subKey, subNonce := deriveX(c.key[:], nonce)
+	return seal(dst, subKey, subNonce, plaintext, additionalData)
+}
+
+// Open authenticates and decrypts ciphertext, authenticates
+// additional data and, if successful, appends the plaintext to dst
+func (c *xchacha20poly1305) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSizeX {
+		panic("chacha20poly1305: bad nonce length passed to Open")
+	}
+	if len(ciphertext) < Overhead {
+		return nil, errOpen
+	}
+	if uint64(len(ciphertext)) > maxPlaintextSize+Overhead {
+		return nil, errOpen
+	}
+	subKey, subNonce := deriveX(c.key[:], nonce)
+	return open(dst, subKey, subNonce, ciphertext, additionalData)
+}
+
+// deriveX derives ChaCha20 key and 96-bit nonce from 192-bit nonce
+func deriveX(key, nonce []byte) ([]byte, []byte) {
+	subKey := chacha.HChaCha20(key, nonce[:16])
+	subNonce := make([]byte, NonceSize)
+	copy(subNonce[4:], nonce[16:])
+	return subKey, subNonce
+}
@@ -0,0 +1,271 @@
+/*
+Command chacha encrypts and decrypts files with XChaCha20-Poly1305
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"ChaCha-Go/chacha"
+	"ChaCha-Go/envelope"
+)
+
+// exit codes
+const (
+	exitOK             = 0
+	exitError          = 1 // I/O error, invalid key or input
+	exitUsage          = 2 // invalid command line
+	exitAuthentication = 3 // modified data or wrong key/password
+)
+
+const usageText = `usage: chacha encrypt|decrypt [flags]
+
+The key (32 bytes) is given by exactly one of -key, -key-file or -key-env,
+or the key is derived with Argon2id from a password given by -password-file
+or -password-env. Input and output default to stdin and stdout.
+Encrypted data starts with a versioned header and is authenticated
+with XChaCha20-Poly1305, unless -raw is used for encryption.
+Data encrypted with -raw is decrypted only with -raw.
+Run 'chacha encrypt -h' for the list of flags.
+`
+
+// secret is the key or the password from the command line
+type secret struct {
+	key      []byte
+	password []byte
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, getenv func(string) string) int {
+	if len(args) == 0 || (args[0] != "encrypt" && args[0] != "decrypt") {
+		fmt.Fprint(stderr, usageText)
+		return exitUsage
+	}
+	command := args[0]
+
+	flags := flag.NewFlagSet("chacha "+command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	keyHex := flags.String("key", "", "key as 64 hex digits")
+	keyFile := flags.String("key-file", "", "read the key from `file` (32 bytes or 64 hex digits)")
+	keyEnv := flags.String("key-env", "", "read the key (64 hex digits) from environment `variable`")
+	passwordFile := flags.String("password-file", "", "read the password from `file` (first line)")
+	passwordEnv := flags.String("password-env", "", "read the password from environment `variable`")
+	in := flags.String("in", "-", "input `file`, - for stdin")
+	out := flags.String("out", "-", "output `file`, - for stdout")
+
+	var raw bool
+	params := envelope.DefaultArgon2Params
+	limits := envelope.DefaultArgon2Limits()
+	memoryMiB := params.Memory / 1024
+	minMemoryMiB := limits.Min.Memory / 1024
+	maxMemoryMiB := limits.Max.Memory / 1024
+	threads := uint(params.Threads)
+	maxThreads := uint(limits.Max.Threads)
+	if command == "encrypt" {
+		flags.BoolVar(&raw, "raw", false, "encrypt with XChaCha20 without authentication")
+		flags.Func("argon-time", fmt.Sprintf("Argon2id passes (default %d)", params.Time), uintFlag(&params.Time))
+		flags.Func("argon-memory", fmt.Sprintf("Argon2id memory in MiB (default %d)", memoryMiB), uintFlag(&memoryMiB))
+		flags.UintVar(&threads, "argon-threads", threads, "Argon2id parallelism (1-255)")
+	} else {
+		flags.BoolVar(&raw, "raw", false, "accept data encrypted with XChaCha20 without authentication")
+		flags.Func("min-argon-time", fmt.Sprintf("reject Argon2id passes below `n` (default %d)", limits.Min.Time), uintFlag(&limits.Min.Time))
+		flags.Func("min-argon-memory", fmt.Sprintf("reject Argon2id memory below `MiB` (default %d)", minMemoryMiB), uintFlag(&minMemoryMiB))
+		flags.Func("max-argon-time", fmt.Sprintf("reject Argon2id passes above `n` (default %d)", limits.Max.Time), uintFlag(&limits.Max.Time))
+		flags.Func("max-argon-memory", fmt.Sprintf("reject Argon2id memory above `MiB` (default %d)", maxMemoryMiB), uintFlag(&maxMemoryMiB))
+		flags.UintVar(&maxThreads, "max-argon-threads", maxThreads, "reject Argon2id parallelism above `n` (1-255)")
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "chacha: unexpected argument %q\n", flags.Arg(0))
+		return exitUsage
+	}
+	if threads == 0 || threads > 255 || maxThreads == 0 || maxThreads > 255 {
+		fmt.Fprintln(stderr, "chacha: -argon-threads and -max-argon-threads must be between 1 and 255")
+		return exitUsage
+	}
+	if max(memoryMiB, minMemoryMiB, maxMemoryMiB) > math.MaxUint32/1024 {
+		fmt.Fprintln(stderr, "chacha: Argon2id memory must be below 4 TiB")
+		return exitUsage
+	}
+	params.Memory = memoryMiB * 1024
+	params.Threads = uint8(threads)
+	limits.Min.Memory = minMemoryMiB * 1024
+	limits.Max.Memory = maxMemoryMiB * 1024
+	limits.Max.Threads = uint8(maxThreads)
+
+	sec, err := loadSecret(*keyHex, *keyFile, *keyEnv, *passwordFile, *passwordEnv, getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "chacha: %v\n", err)
+		return exitUsage
+	}
+	input, err := readInput(*in, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "chacha: %v\n", err)
+		return exitError
+	}
+
+	var output []byte
+	switch {
+	case command == "encrypt":
+		algorithm := envelope.XChaCha20Poly1305
+		if raw {
+			algorithm = envelope.XChaCha20
+		}
+		if sec.password != nil {
+			output, err = envelope.SealWithPassword(sec.password, input, algorithm, params)
+		} else {
+			output, err = envelope.Seal(sec.key, input, algorithm)
+		}
+	case sec.password != nil && raw:
+		output, err = envelope.OpenUnauthenticatedWithPassword(sec.password, input, limits)
+	case sec.password != nil:
+		output, err = envelope.OpenWithPassword(sec.password, input, limits)
+	case raw:
+		output, err = envelope.OpenUnauthenticated(sec.key, input)
+	default:
+		output, err = envelope.Open(sec.key, input)
+	}
+	if errors.Is(err, envelope.ErrUnauthenticated) {
+		fmt.Fprintln(stderr, "chacha: the data is not authenticated, use -raw to decrypt it anyway")
+		return exitAuthentication
+	}
+	if errors.Is(err, envelope.ErrAuthentication) {
+		fmt.Fprintln(stderr, "chacha: authentication failed, the data was modified or the key/password is wrong")
+		return exitAuthentication
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "chacha: %s failed: %v\n", command, err)
+		return exitError
+	}
+
+	if err := writeOutput(*out, stdout, output); err != nil {
+		fmt.Fprintf(stderr, "chacha: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// uintFlag parses flag value into a uint32
+func uintFlag(v *uint32) func(string) error {
+	return func(s string) error {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || n == 0 {
+			return errors.New("expected a positive number")
+		}
+		*v = uint32(n)
+		return nil
+	}
+}
+
+// loadSecret returns the key or the password from the only source which is set
+func loadSecret(keyHex, keyFile, keyEnv, passwordFile, passwordEnv string, getenv func(string) string) (*secret, error) {
+	sources := 0
+	for _, source := range []string{keyHex, keyFile, keyEnv, passwordFile, passwordEnv} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, errors.New("exactly one of -key, -key-file, -key-env, -password-file and -password-env is required")
+	}
+
+	switch {
+	case keyFile != "":
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == chacha.KeySize {
+			return &secret{key: data}, nil
+		}
+		return parseKey(string(data))
+	case keyEnv != "":
+		value := getenv(keyEnv)
+		if value == "" {
+			return nil, fmt.Errorf("environment variable %s is not set", keyEnv)
+		}
+		return parseKey(value)
+	case keyHex != "":
+		return parseKey(keyHex)
+	case passwordFile != "":
+		data, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return nil, err
+		}
+		if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+			data = data[:i]
+		}
+		return newPassword(data)
+	default:
+		return newPassword([]byte(getenv(passwordEnv)))
+	}
+}
+
+// parseKey decodes key given as 64 hex digits
+func parseKey(s string) (*secret, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != chacha.KeySize {
+		return nil, fmt.Errorf("invalid key, expected %d bytes as %d hex digits", chacha.KeySize, 2*chacha.KeySize)
+	}
+	return &secret{key: key}, nil
+}
+
+func newPassword(password []byte) (*secret, error) {
+	if len(password) == 0 {
+		return nil, errors.New("empty password")
+	}
+	return &secret{password: password}, nil
+}
+
+func readInput(name string, stdin io.Reader) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// writeOutput writes the output only after successful encryption/decryption,
+// so nothing is written when the authentication fails
+func writeOutput(name string, stdout io.Writer, data []byte) error {
+	if name == "-" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(name, data, 0o600)
+}
@@ -0,0 +1,239 @@
+/*
+Command chacha encrypts and decrypts files with XChaCha20-Poly1305
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+const (
+	testKeyHex   = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testPassword = "correct horse battery staple"
+)
+
+// cheapArgon2 are the lowest Argon2id parameters accepted by default
+var cheapArgon2 = []string{"-argon-time", "2", "-argon-memory", "19", "-argon-threads", "1"}
+
+// runCommand runs the command with passed stdin
+// and returns the exit code, stdout and stderr
+func runCommand(stdin []byte, args ...string) (int, []byte, string) {
+	var stdout, stderr bytes.Buffer
+	getenv := func(name string) string {
+		switch name {
+		case "CHACHA_KEY":
+			return testKeyHex
+		case "CHACHA_PASSWORD":
+			return testPassword
+		}
+		return ""
+	}
+	code := run(args, bytes.NewReader(stdin), &stdout, &stderr, getenv)
+	return code, stdout.Bytes(), stderr.String()
+}
+
+func Test_EncryptDecrypt(t *testing.T) {
+	plaintext := []byte("Ladies and Gentlemen of the class of '99")
+
+	for _, raw := range []bool{false, true} {
+		args := []string{"encrypt", "-key", testKeyHex}
+		if raw {
+			args = append(args, "-raw")
+		}
+		code, encrypted, stderr := runCommand(plaintext, args...)
+		if code != exitOK {
+			t.Fatalf("encrypt exited with %d: %s", code, stderr)
+		}
+		args[0] = "decrypt"
+		code, decrypted, stderr := runCommand(encrypted, args...)
+		if code != exitOK {
+			t.Fatalf("decrypt exited with %d: %s", code, stderr)
+		}
+		if !shared.AreByteSlicesEqual(decrypted, plaintext) {
+			t.Errorf("raw %v: invalid round trip", raw)
+		}
+	}
+}
+
+func Test_RawRequired(t *testing.T) {
+	_, encrypted, _ := runCommand([]byte("secret"), "encrypt", "-key", testKeyHex, "-raw")
+	code, _, stderr := runCommand(encrypted, "decrypt", "-key", testKeyHex)
+	if code != exitAuthentication || !strings.Contains(stderr, "-raw") {
+		t.Errorf("raw data without -raw: exit code %d, message %q", code, stderr)
+	}
+
+	// authenticated data downgraded to XChaCha20 in the header
+	_, encrypted, _ = runCommand([]byte("secret"), "encrypt", "-key", testKeyHex)
+	encrypted[5] = 2
+	if code, _, _ := runCommand(encrypted, "decrypt", "-key", testKeyHex); code != exitAuthentication {
+		t.Errorf("downgraded header: expected exit code %d, got %d", exitAuthentication, code)
+	}
+}
+
+func Test_Files(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	plainFile := filepath.Join(dir, "plain")
+	encryptedFile := filepath.Join(dir, "encrypted")
+	decryptedFile := filepath.Join(dir, "decrypted")
+
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	plaintext := bytes.Repeat([]byte("chacha"), 1000)
+	os.WriteFile(keyFile, key, 0o600)
+	os.WriteFile(plainFile, plaintext, 0o600)
+
+	if code, _, stderr := runCommand(nil, "encrypt", "-key-file", keyFile, "-in", plainFile, "-out", encryptedFile); code != exitOK {
+		t.Fatalf("encrypt exited with %d: %s", code, stderr)
+	}
+	// key file with hex digits and a new line
+	os.WriteFile(keyFile, []byte(testKeyHex+"\n"), 0o600)
+	if code, _, stderr := runCommand(nil, "decrypt", "-key-file", keyFile, "-in", encryptedFile, "-out", decryptedFile); code != exitOK {
+		t.Fatalf("decrypt exited with %d: %s", code, stderr)
+	}
+	decrypted, _ := os.ReadFile(decryptedFile)
+	if !shared.AreByteSlicesEqual(decrypted, plaintext) {
+		t.Error("invalid round trip through files")
+	}
+}
+
+func Test_Password(t *testing.T) {
+	plaintext := []byte("Ladies and Gentlemen of the class of '99")
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	os.WriteFile(passwordFile, []byte(testPassword+"\n"), 0o600)
+
+	args := append([]string{"encrypt", "-password-env", "CHACHA_PASSWORD"}, cheapArgon2...)
+	code, encrypted, stderr := runCommand(plaintext, args...)
+	if code != exitOK {
+		t.Fatalf("encrypt exited with %d: %s", code, stderr)
+	}
+	code, decrypted, stderr := runCommand(encrypted, "decrypt", "-password-file", passwordFile)
+	if code != exitOK {
+		t.Fatalf("decrypt exited with %d: %s", code, stderr)
+	}
+	if !shared.AreByteSlicesEqual(decrypted, plaintext) {
+		t.Error("invalid round trip with password")
+	}
+
+	os.WriteFile(passwordFile, []byte("wrong password\n"), 0o600)
+	if code, _, _ := runCommand(encrypted, "decrypt", "-password-file", passwordFile); code != exitAuthentication {
+		t.Errorf("wrong password: expected exit code %d, got %d", exitAuthentication, code)
+	}
+	code, _, stderr = runCommand(encrypted, "decrypt", "-password-env", "CHACHA_PASSWORD", "-min-argon-memory", "64")
+	if code != exitError || !strings.Contains(stderr, "below the minimum") {
+		t.Errorf("weak parameters: exit code %d, message %q", code, stderr)
+	}
+	code, _, stderr = runCommand(encrypted, "decrypt", "-password-env", "CHACHA_PASSWORD", "-max-argon-time", "1")
+	if code != exitError || !strings.Contains(stderr, "above the limit") {
+		t.Errorf("costly parameters: exit code %d, message %q", code, stderr)
+	}
+	code, _, _ = runCommand(encrypted, "decrypt", "-password-env", "CHACHA_PASSWORD",
+		"-max-argon-time", "2", "-max-argon-memory", "19", "-max-argon-threads", "1")
+	if code != exitOK {
+		t.Errorf("parameters at the limit: expected exit code %d, got %d", exitOK, code)
+	}
+	if code, _, _ := runCommand(encrypted, "decrypt", "-key", testKeyHex); code != exitError {
+		t.Errorf("key for password data: expected exit code %d, got %d", exitError, code)
+	}
+}
+
+func Test_PasswordAboveLimits(t *testing.T) {
+	plaintext := []byte("Wear sunscreen")
+	code, encrypted, stderr := runCommand(plaintext, "encrypt", "-password-env", "CHACHA_PASSWORD",
+		"-argon-time", "11", "-argon-memory", "19", "-argon-threads", "1")
+	if code != exitOK {
+		t.Fatalf("encrypt exited with %d: %s", code, stderr)
+	}
+	code, _, stderr = runCommand(encrypted, "decrypt", "-password-env", "CHACHA_PASSWORD")
+	if code != exitError || !strings.Contains(stderr, "above the limit") {
+		t.Errorf("default limits: exit code %d, message %q", code, stderr)
+	}
+	code, decrypted, stderr := runCommand(encrypted, "decrypt", "-password-env", "CHACHA_PASSWORD", "-max-argon-time", "11")
+	if code != exitOK {
+		t.Fatalf("raised limit: decrypt exited with %d: %s", code, stderr)
+	}
+	if !shared.AreByteSlicesEqual(decrypted, plaintext) {
+		t.Error("invalid round trip above the default limits")
+	}
+}
+
+func Test_AuthenticationFailure(t *testing.T) {
+	_, encrypted, _ := runCommand([]byte("secret"), "encrypt", "-key", testKeyHex)
+	encrypted[len(encrypted)-1] ^= 0x01
+
+	outFile := filepath.Join(t.TempDir(), "out")
+	code, _, stderr := runCommand(encrypted, "decrypt", "-key", testKeyHex, "-out", outFile)
+	if code != exitAuthentication {
+		t.Errorf("expected exit code %d, got %d", exitAuthentication, code)
+	}
+	if !strings.Contains(stderr, "authentication failed") {
+		t.Errorf("unclear message %q", stderr)
+	}
+	if _, err := os.Stat(outFile); !os.IsNotExist(err) {
+		t.Error("output written despite authentication failure")
+	}
+}
+
+func Test_UsageErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"shuffle"}, exitUsage},
+		{"no key", []string{"encrypt"}, exitUsage},
+		{"two keys", []string{"encrypt", "-key", testKeyHex, "-key-env", "CHACHA_KEY"}, exitUsage},
+		{"short key", []string{"encrypt", "-key", "0011"}, exitUsage},
+		{"unset variable", []string{"encrypt", "-key-env", "NO_SUCH_KEY"}, exitUsage},
+		{"key and password", []string{"encrypt", "-key", testKeyHex, "-password-env", "CHACHA_PASSWORD"}, exitUsage},
+		{"empty password", []string{"encrypt", "-password-env", "NO_SUCH_PASSWORD"}, exitUsage},
+		{"no threads", []string{"encrypt", "-password-env", "CHACHA_PASSWORD", "-argon-threads", "0"}, exitUsage},
+		{"zero memory", []string{"encrypt", "-password-env", "CHACHA_PASSWORD", "-argon-memory", "0"}, exitUsage},
+		{"huge memory", []string{"encrypt", "-password-env", "CHACHA_PASSWORD", "-argon-memory", "4194304"}, exitUsage},
+		{"no max threads", []string{"decrypt", "-password-env", "CHACHA_PASSWORD", "-max-argon-threads", "0"}, exitUsage},
+		{"weak parameters", []string{"encrypt", "-password-env", "CHACHA_PASSWORD", "-argon-memory", "8"}, exitError},
+		{"extra argument", []string{"encrypt", "-key", testKeyHex, "file"}, exitUsage},
+		{"missing input", []string{"encrypt", "-key", testKeyHex, "-in", "/no/such/file"}, exitError},
+		{"not encrypted", []string{"decrypt", "-key", testKeyHex}, exitError},
+	}
+	for _, c := range cases {
+		code, _, stderr := runCommand([]byte("plain text"), c.args...)
+		if code != c.code {
+			t.Errorf("%s: expected exit code %d, got %d (%s)", c.name, c.code, code, stderr)
+		}
+		if stderr == "" {
+			t.Errorf("%s: no message", c.name)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+/*
+Package envelope implements versioned file format for data encrypted with XChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"ChaCha-Go/chacha"
+	"ChaCha-Go/chacha20poly1305"
+)
+
+// Layout of the header (version 1):
+//
+//	magic      4 bytes  "CCGO"
+//	version    1 byte   1
+//	algorithm  1 byte   XChaCha20Poly1305 or XChaCha20
+//	kdf        1 byte   KeyRaw or KeyArgon2id
+//	time       4 bytes  Argon2id passes, little endian   (KeyArgon2id only)
+//	memory     4 bytes  Argon2id memory in KiB           (KeyArgon2id only)
+//	threads    1 byte   Argon2id parallelism             (KeyArgon2id only)
+//	salt      16 bytes  random Argon2id salt             (KeyArgon2id only)
+//	nonce     24 bytes  random XChaCha20 nonce
+//
+// The header is followed by the ciphertext. XChaCha20-Poly1305
+// authenticates the header as additional data and appends the tag.
+const (
+	Version1   = 1
+	HeaderSize = 4 + 3 + chacha.XNonceSize // in bytes, version 1 header with KeyRaw
+	SaltSize   = 16                        // in bytes, Argon2id salt
+
+	argon2HeaderSize = 4 + 4 + 1 + SaltSize // in bytes, Argon2id parameters and salt
+)
+
+var magic = []byte("CCGO")
+
+// Algorithm identifies the cipher of the encrypted data
+type Algorithm byte
+
+const (
+	// XChaCha20Poly1305 is authenticated encryption, the default
+	XChaCha20Poly1305 Algorithm = 1
+	// XChaCha20 is the stream cipher without authentication,
+	// modified data is not detected
+	XChaCha20 Algorithm = 2
+)
+
+// KDF identifies how the key is obtained
+type KDF byte
+
+const (
+	// KeyRaw means 32-byte key passed by the caller
+	KeyRaw KDF = 0
+	// KeyArgon2id means key derived from a password with Argon2id
+	KeyArgon2id KDF = 1
+)
+
+var (
+	// ErrFormat is returned for data which is not an envelope or is truncated
+	ErrFormat = errors.New("envelope: invalid header")
+	// ErrAuthentication is returned if the data or the header was modified
+	// or the key is wrong
+	ErrAuthentication = errors.New("envelope: message authentication failed")
+	// ErrPasswordRequired is returned by Open for data encrypted with a password
+	ErrPasswordRequired = errors.New("envelope: data is encrypted with a password")
+	// ErrKeyRequired is returned by OpenWithPassword for data encrypted with a key
+	ErrKeyRequired = errors.New("envelope: data is encrypted with a key")
+	// ErrUnauthenticated is returned by Open and OpenWithPassword for data
+	// encrypted with XChaCha20. The algorithm byte is not protected by
+	// anything, so such data may be an authenticated file whose header
+	// was modified to skip the verification.
+	ErrUnauthenticated = errors.New("envelope: data is not authenticated")
+)
+
+// VersionError is returned for header with unsupported version
+type VersionError byte
+
+func (v VersionError) Error() string {
+	return "envelope: unsupported version " + strconv.Itoa(int(v))
+}
+
+// AlgorithmError is returned for header with unknown algorithm
+type AlgorithmError byte
+
+func (a AlgorithmError) Error() string {
+	return "envelope: unknown algorithm " + strconv.Itoa(int(a))
+}
+
+// Header is the beginning of the encrypted data
+type Header struct {
+	Version   byte
+	Algorithm Algorithm
+	KDF       KDF
+	Argon2    Argon2Params // KeyArgon2id only
+	Salt      []byte       // 16 bytes, KeyArgon2id only
+	Nonce     []byte       // 24 bytes
+}
+
+// MarshalBinary encodes the header
+func (h *Header) MarshalBinary() ([]byte, error) {
+	if len(h.Nonce) != chacha.XNonceSize {
+		return nil, chacha.NonceSizeError(len(h.Nonce))
+	}
+	out := make([]byte, 0, HeaderSize+argon2HeaderSize)
+	out = append(out, magic...)
+	out = append(out, h.Version, byte(h.Algorithm), byte(h.KDF))
+	if h.KDF == KeyArgon2id {
+		if len(h.Salt) != SaltSize {
+			return nil, errors.New("envelope: invalid salt size " + strconv.Itoa(len(h.Salt)))
+		}
+		var params [9]byte
+		binary.LittleEndian.PutUint32(params[0:], h.Argon2.Time)
+		binary.LittleEndian.PutUint32(params[4:], h.Argon2.Memory)
+		params[8] = h.Argon2.Threads
+		out = append(out, params[:]...)
+		out = append(out, h.Salt...)
+	}
+	return append(out, h.Nonce...), nil
+}
+
+// ParseHeader decodes the header at the beginning of data.
+// It returns the header and its size in bytes.
+func ParseHeader(data []byte) (*Header, int, error) {
+	if len(data) < len(magic)+1 || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, 0, ErrFormat
+	}
+	if version := data[len(magic)]; version != Version1 {
+		return nil, 0, VersionError(version)
+	}
+	if len(data) < HeaderSize {
+		return nil, 0, ErrFormat
+	}
+
+	h := &Header{
+		Version:   data[4],
+		Algorithm: Algorithm(data[5]),
+		KDF:       KDF(data[6]),
+	}
+	if h.Algorithm != XChaCha20Poly1305 && h.Algorithm != XChaCha20 {
+		return nil, 0, AlgorithmError(h.Algorithm)
+	}
+
+	n := 7
+	switch h.KDF {
+	case KeyRaw:
+	case KeyArgon2id:
+		if len(data) < HeaderSize+argon2HeaderSize {
+			return nil, 0, ErrFormat
+		}
+		h.Argon2.Time = binary.LittleEndian.Uint32(data[n:])
+		h.Argon2.Memory = binary.LittleEndian.Uint32(data[n+4:])
+		h.Argon2.Threads = data[n+8]
+		h.Salt = append([]byte(nil), data[n+9:n+argon2HeaderSize]...)
+		n += argon2HeaderSize
+	default:
+		return nil, 0, ErrFormat
+	}
+	h.Nonce = append([]byte(nil), data[n:n+chacha.XNonceSize]...)
+	return h, n + chacha.XNonceSize, nil
+}
+
+// Seal encrypts plaintext with passed 32-byte key and random nonce
+// and returns the header followed by the ciphertext
+func Seal(key, plaintext []byte, algorithm Algorithm) ([]byte, error) {
+	if len(key) != chacha.KeySize {
+		return nil, chacha.KeySizeError(len(key))
+	}
+	h, err := newHeader(algorithm, KeyRaw)
+	if err != nil {
+		return nil, err
+	}
+	return seal(key, h, plaintext)
+}
+
+// Open decrypts data created by Seal with passed 32-byte key.
+// It returns ErrAuthentication if the data was modified
+// and ErrUnauthenticated for data encrypted with XChaCha20.
+func Open(key, data []byte) ([]byte, error) {
+	return openWithKey(key, data, false)
+}
+
+// OpenUnauthenticated decrypts data created by Seal like Open,
+// but it also accepts data encrypted with XChaCha20, which is
+// decrypted without any verification. Use it only for data
+// which is known to be encrypted without authentication.
+func OpenUnauthenticated(key, data []byte) ([]byte, error) {
+	return openWithKey(key, data, true)
+}
+
+func openWithKey(key, data []byte, unauthenticated bool) ([]byte, error) {
+	if len(key) != chacha.KeySize {
+		return nil, chacha.KeySizeError(len(key))
+	}
+	h, n, err := ParseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if h.KDF != KeyRaw {
+		return nil, ErrPasswordRequired
+	}
+	if h.Algorithm == XChaCha20 && !unauthenticated {
+		return nil, ErrUnauthenticated
+	}
+	return open(key, h, data[:n], data[n:])
+}
+
+// newHeader creates version 1 header with random nonce
+func newHeader(algorithm Algorithm, kdf KDF) (*Header, error) {
+	if algorithm != XChaCha20Poly1305 && algorithm != XChaCha20 {
+		return nil, AlgorithmError(algorithm)
+	}
+	h := &Header{
+		Version:   Version1,
+		Algorithm: algorithm,
+		KDF:       kdf,
+		Nonce:     make([]byte, chacha.XNonceSize),
+	}
+	if _, err := rand.Read(h.Nonce); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func seal(key []byte, h *Header, plaintext []byte) ([]byte, error) {
+	header, err := h.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Algorithm == XChaCha20 {
+		cc, err := chacha.NewXCipher(key, h.Nonce, 0)
+		if err != nil {
+			return nil, err
+		}
+		ciphertext, err := cc.CipherChecked(plaintext)
+		if err != nil {
+			return nil, err
+		}
+		return append(header, ciphertext...), nil
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, h.Nonce, plaintext, header), nil
+}
+
+func open(key []byte, h *Header, header, ciphertext []byte) ([]byte, error) {
+	if h.Algorithm == XChaCha20 {
+		cc, err := chacha.NewXCipher(key, h.Nonce, 0)
+		if err != nil {
+			return nil, err
+		}
+		return cc.CipherChecked(ciphertext)
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, h.Nonce, ciphertext, header)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,145 @@
+/*
+Package envelope implements versioned file format for data encrypted with XChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package envelope
+
+import (
+	"errors"
+	"testing"
+
+	"ChaCha-Go/chacha"
+	"ChaCha-Go/shared"
+)
+
+func testKey() []byte {
+	key := make([]byte, chacha.KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func Test_SealOpen(t *testing.T) {
+	key := testKey()
+	for _, algorithm := range []Algorithm{XChaCha20Poly1305, XChaCha20} {
+		for _, n := range []int{0, 1, 64, 1000} {
+			plaintext := make([]byte, n)
+			for i := range plaintext {
+				plaintext[i] = byte(i * 7)
+			}
+			data, err := Seal(key, plaintext, algorithm)
+			if err != nil {
+				t.Fatalf("Seal returned %v", err)
+			}
+			h, size, err := ParseHeader(data)
+			if err != nil || size != HeaderSize || h.Version != Version1 || h.Algorithm != algorithm {
+				t.Fatalf("invalid header %+v, %d, %v", h, size, err)
+			}
+			decrypted, err := OpenUnauthenticated(key, data)
+			if err != nil {
+				t.Fatalf("OpenUnauthenticated returned %v", err)
+			}
+			if !shared.AreByteSlicesEqual(decrypted, plaintext) {
+				t.Errorf("algorithm %d, %d bytes: invalid round trip", algorithm, n)
+			}
+		}
+	}
+}
+
+func Test_OpenModified(t *testing.T) {
+	key := testKey()
+	data, _ := Seal(key, []byte("attack at dawn"), XChaCha20Poly1305)
+
+	for i := range data {
+		modified := append([]byte(nil), data...)
+		modified[i] ^= 0x01
+		if _, err := Open(key, modified); err == nil {
+			t.Errorf("modified byte %d not detected", i)
+		}
+	}
+
+	wrongKey := testKey()
+	wrongKey[0] ^= 0x01
+	if _, err := Open(wrongKey, data); err != ErrAuthentication {
+		t.Errorf("wrong key: expected ErrAuthentication, got %v", err)
+	}
+	if _, err := Open(key, data[:len(data)-1]); err != ErrAuthentication {
+		t.Errorf("truncated tag: expected ErrAuthentication, got %v", err)
+	}
+}
+
+func Test_OpenDowngrade(t *testing.T) {
+	key := testKey()
+	data, _ := Seal(key, []byte("attack at dawn"), XChaCha20Poly1305)
+	if _, err := OpenUnauthenticated(key, data); err != nil {
+		t.Errorf("OpenUnauthenticated returned %v for authenticated data", err)
+	}
+
+	modified := append([]byte(nil), data...)
+	modified[5] = byte(XChaCha20)
+	if _, err := Open(key, modified); err != ErrUnauthenticated {
+		t.Errorf("downgraded header: expected ErrUnauthenticated, got %v", err)
+	}
+
+	data, _ = Seal(key, []byte("attack at dawn"), XChaCha20)
+	if _, err := Open(key, data); err != ErrUnauthenticated {
+		t.Errorf("expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func Test_ParseHeaderErrors(t *testing.T) {
+	data, _ := Seal(testKey(), nil, XChaCha20Poly1305)
+
+	if _, _, err := ParseHeader([]byte("not an envelope")); err != ErrFormat {
+		t.Errorf("expected ErrFormat, got %v", err)
+	}
+	if _, _, err := ParseHeader(data[:HeaderSize-1]); err != ErrFormat {
+		t.Errorf("truncated header: expected ErrFormat, got %v", err)
+	}
+
+	modified := append([]byte(nil), data...)
+	modified[4] = 99
+	var versionErr VersionError
+	if _, _, err := ParseHeader(modified); !errors.As(err, &versionErr) || versionErr != 99 {
+		t.Errorf("expected VersionError, got %v", err)
+	}
+
+	modified = append([]byte(nil), data...)
+	modified[5] = 99
+	var algorithmErr AlgorithmError
+	if _, _, err := ParseHeader(modified); !errors.As(err, &algorithmErr) {
+		t.Errorf("expected AlgorithmError, got %v", err)
+	}
+}
+
+func Test_SealInvalidKey(t *testing.T) {
+	var keySizeErr chacha.KeySizeError
+	if _, err := Seal(make([]byte, 16), nil, XChaCha20Poly1305); !errors.As(err, &keySizeErr) {
+		t.Errorf("expected KeySizeError, got %v", err)
+	}
+	if _, err := Open(make([]byte, 16), nil); !errors.As(err, &keySizeErr) {
+		t.Errorf("expected KeySizeError, got %v", err)
+	}
+}
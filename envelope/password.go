@@ -0,0 +1,178 @@
+/*
+Package envelope implements versioned file format for data encrypted with XChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package envelope
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+
+	"ChaCha-Go/chacha"
+)
+
+// Argon2Params are cost parameters of Argon2id (RFC 9106).
+// Higher values make guessing the password slower, and
+// derivation of the key slower for the legitimate user.
+type Argon2Params struct {
+	Time    uint32 // number of passes over the memory
+	Memory  uint32 // in KiB
+	Threads uint8  // degree of parallelism
+}
+
+var (
+	// DefaultArgon2Params are used for encryption unless tuned by the caller,
+	// derivation takes about a second and 256 MiB of memory
+	DefaultArgon2Params = Argon2Params{Time: 3, Memory: 256 * 1024, Threads: 4}
+
+	// MinArgon2Params are the lowest parameters accepted by default,
+	// the minimum recommended by OWASP (19 MiB, 2 passes)
+	MinArgon2Params = Argon2Params{Time: 2, Memory: 19 * 1024, Threads: 1}
+
+	// MaxArgon2Params are the highest parameters accepted by default,
+	// data from untrusted sources shouldn't be able to exhaust
+	// the memory or keep the CPU busy for minutes (1 GiB, 10 passes)
+	MaxArgon2Params = Argon2Params{Time: 10, Memory: 1024 * 1024, Threads: 16}
+)
+
+// Argon2Limits are the bounds of Argon2id parameters accepted in headers
+type Argon2Limits struct {
+	Min, Max Argon2Params
+}
+
+// DefaultArgon2Limits returns MinArgon2Params and MaxArgon2Params as limits
+func DefaultArgon2Limits() Argon2Limits {
+	return Argon2Limits{Min: MinArgon2Params, Max: MaxArgon2Params}
+}
+
+// WeakParamsError is returned for Argon2id
+// parameters below the required minimum
+type WeakParamsError struct {
+	Params, Min Argon2Params
+}
+
+func (e *WeakParamsError) Error() string {
+	return fmt.Sprintf("envelope: Argon2id parameters (time %d, memory %d KiB) below the minimum (time %d, memory %d KiB)",
+		e.Params.Time, e.Params.Memory, e.Min.Time, e.Min.Memory)
+}
+
+// CostlyParamsError is returned for Argon2id
+// parameters above the allowed maximum
+type CostlyParamsError struct {
+	Params, Max Argon2Params
+}
+
+func (e *CostlyParamsError) Error() string {
+	return fmt.Sprintf("envelope: Argon2id parameters (time %d, memory %d KiB, threads %d) above the limit (time %d, memory %d KiB, threads %d)",
+		e.Params.Time, e.Params.Memory, e.Params.Threads, e.Max.Time, e.Max.Memory, e.Max.Threads)
+}
+
+// check returns WeakParamsError or CostlyParamsError if the parameters
+// are outside the limits and an error if they are invalid
+func (l Argon2Limits) check(p Argon2Params) error {
+	if p.Time > l.Max.Time || p.Memory > l.Max.Memory || p.Threads > l.Max.Threads {
+		return &CostlyParamsError{Params: p, Max: l.Max}
+	}
+	return checkMin(p, l.Min)
+}
+
+// checkMin returns WeakParamsError if the parameters are below min
+// and an error if they are invalid (argon2 panics for them)
+func checkMin(p, min Argon2Params) error {
+	if p.Time == 0 {
+		return errors.New("envelope: invalid Argon2id time 0")
+	}
+	if p.Threads == 0 {
+		return errors.New("envelope: invalid Argon2id parallelism 0")
+	}
+	if p.Time < min.Time || p.Memory < min.Memory {
+		return &WeakParamsError{Params: p, Min: min}
+	}
+	return nil
+}
+
+// deriveKey derives 32-byte key from the password with Argon2id
+func deriveKey(password, salt []byte, params Argon2Params) []byte {
+	return argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, chacha.KeySize)
+}
+
+// SealWithPassword encrypts plaintext with key derived from the password
+// with Argon2id. The parameters and random salt are stored in the header.
+// It returns WeakParamsError for parameters below MinArgon2Params.
+// Parameters above MaxArgon2Params are accepted, but such data
+// must be opened with raised limits.
+func SealWithPassword(password, plaintext []byte, algorithm Algorithm, params Argon2Params) ([]byte, error) {
+	if err := checkMin(params, MinArgon2Params); err != nil {
+		return nil, err
+	}
+	h, err := newHeader(algorithm, KeyArgon2id)
+	if err != nil {
+		return nil, err
+	}
+	h.Argon2 = params
+	h.Salt = make([]byte, SaltSize)
+	if _, err := rand.Read(h.Salt); err != nil {
+		return nil, err
+	}
+	return seal(deriveKey(password, h.Salt, params), h, plaintext)
+}
+
+// OpenWithPassword decrypts data created by SealWithPassword.
+// Headers with parameters outside passed limits are rejected with
+// WeakParamsError or CostlyParamsError before the key is derived,
+// use DefaultArgon2Limits unless data encrypted with lower or higher
+// parameters must be read.
+// It returns ErrAuthentication if the data was modified
+// or the password is wrong, and ErrUnauthenticated for data
+// encrypted with XChaCha20.
+func OpenWithPassword(password, data []byte, limits Argon2Limits) ([]byte, error) {
+	return openWithPassword(password, data, limits, false)
+}
+
+// OpenUnauthenticatedWithPassword decrypts data created by SealWithPassword
+// like OpenWithPassword, but it also accepts data encrypted with XChaCha20,
+// which is decrypted without any verification.
+func OpenUnauthenticatedWithPassword(password, data []byte, limits Argon2Limits) ([]byte, error) {
+	return openWithPassword(password, data, limits, true)
+}
+
+func openWithPassword(password, data []byte, limits Argon2Limits, unauthenticated bool) ([]byte, error) {
+	h, n, err := ParseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if h.KDF != KeyArgon2id {
+		return nil, ErrKeyRequired
+	}
+	if h.Algorithm == XChaCha20 && !unauthenticated {
+		return nil, ErrUnauthenticated
+	}
+	if err := limits.check(h.Argon2); err != nil {
+		return nil, err
+	}
+	return open(deriveKey(password, h.Salt, h.Argon2), h, data[:n], data[n:])
+}
@@ -0,0 +1,149 @@
+/*
+Package envelope implements versioned file format for data encrypted with XChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package envelope
+
+import (
+	"errors"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+// testParams are cheap parameters, tests lower MinArgon2Params to accept them
+var testParams = Argon2Params{Time: 1, Memory: 64, Threads: 1}
+
+var testLimits = Argon2Limits{Min: testParams, Max: MaxArgon2Params}
+
+func lowerMinParams(t *testing.T) {
+	saved := MinArgon2Params
+	MinArgon2Params = testParams
+	t.Cleanup(func() { MinArgon2Params = saved })
+}
+
+func Test_SealOpenWithPassword(t *testing.T) {
+	lowerMinParams(t)
+	password := []byte("correct horse battery staple")
+	plaintext := []byte("attack at dawn")
+
+	for _, algorithm := range []Algorithm{XChaCha20Poly1305, XChaCha20} {
+		data, err := SealWithPassword(password, plaintext, algorithm, testParams)
+		if err != nil {
+			t.Fatalf("SealWithPassword returned %v", err)
+		}
+		h, size, err := ParseHeader(data)
+		if err != nil || size != HeaderSize+argon2HeaderSize || h.KDF != KeyArgon2id ||
+			h.Argon2 != testParams || len(h.Salt) != SaltSize {
+			t.Fatalf("invalid header %+v, %d, %v", h, size, err)
+		}
+		decrypted, err := OpenUnauthenticatedWithPassword(password, data, testLimits)
+		if err != nil {
+			t.Fatalf("OpenUnauthenticatedWithPassword returned %v", err)
+		}
+		if !shared.AreByteSlicesEqual(decrypted, plaintext) {
+			t.Errorf("algorithm %d: invalid round trip", algorithm)
+		}
+	}
+
+	data, _ := SealWithPassword(password, plaintext, XChaCha20Poly1305, testParams)
+	if _, err := OpenWithPassword([]byte("wrong password"), data, testLimits); err != ErrAuthentication {
+		t.Errorf("wrong password: expected ErrAuthentication, got %v", err)
+	}
+	modified := append([]byte(nil), data...)
+	modified[16] ^= 0x01 // first byte of the salt
+	if _, err := OpenWithPassword(password, modified, testLimits); err != ErrAuthentication {
+		t.Errorf("modified salt: expected ErrAuthentication, got %v", err)
+	}
+	modified = append([]byte(nil), data...)
+	modified[5] = byte(XChaCha20)
+	if _, err := OpenWithPassword(password, modified, testLimits); err != ErrUnauthenticated {
+		t.Errorf("downgraded header: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func Test_WeakParams(t *testing.T) {
+	var weakErr *WeakParamsError
+	if _, err := SealWithPassword([]byte("password"), nil, XChaCha20Poly1305, testParams); !errors.As(err, &weakErr) {
+		t.Errorf("seal: expected WeakParamsError, got %v", err)
+	}
+
+	lowerMinParams(t)
+	data, _ := SealWithPassword([]byte("password"), nil, XChaCha20Poly1305, testParams)
+	limits := Argon2Limits{Min: Argon2Params{Time: 1, Memory: 128}, Max: MaxArgon2Params}
+	if _, err := OpenWithPassword([]byte("password"), data, limits); !errors.As(err, &weakErr) || weakErr.Min != limits.Min {
+		t.Errorf("open: expected WeakParamsError, got %v", err)
+	}
+}
+
+func Test_CostlyParams(t *testing.T) {
+	lowerMinParams(t)
+	var costlyErr *CostlyParamsError
+	params := Argon2Params{Time: MaxArgon2Params.Time + 1, Memory: 64, Threads: MaxArgon2Params.Threads + 1}
+	data, err := SealWithPassword([]byte("password"), nil, XChaCha20Poly1305, params)
+	if err != nil {
+		t.Fatalf("seal above the default limits: SealWithPassword returned %v", err)
+	}
+	if _, err := OpenWithPassword([]byte("password"), data, testLimits); !errors.As(err, &costlyErr) || costlyErr.Max != testLimits.Max {
+		t.Errorf("above the default limits: expected CostlyParamsError, got %v", err)
+	}
+
+	data, _ = SealWithPassword([]byte("password"), nil, XChaCha20Poly1305, Argon2Params{Time: 2, Memory: 64, Threads: 2})
+	limits := Argon2Limits{Min: testParams, Max: Argon2Params{Time: 1, Memory: 64, Threads: 2}}
+	if _, err := OpenWithPassword([]byte("password"), data, limits); !errors.As(err, &costlyErr) || costlyErr.Max != limits.Max {
+		t.Errorf("time above the limit: expected CostlyParamsError, got %v", err)
+	}
+	limits.Max.Time = 2
+	if _, err := OpenWithPassword([]byte("password"), data, limits); err != nil {
+		t.Errorf("raised limit: OpenWithPassword returned %v", err)
+	}
+}
+
+func Test_InvalidParams(t *testing.T) {
+	lowerMinParams(t)
+	limits := Argon2Limits{Max: MaxArgon2Params}
+	for _, params := range []Argon2Params{
+		{Time: 0, Memory: 64, Threads: 1},
+		{Time: 1, Memory: 64, Threads: 0},
+	} {
+		if _, err := SealWithPassword([]byte("password"), nil, XChaCha20Poly1305, params); err == nil {
+			t.Errorf("seal %+v: no error", params)
+		}
+		if err := limits.check(params); err == nil {
+			t.Errorf("check %+v: no error", params)
+		}
+	}
+}
+
+func Test_KeyPasswordMismatch(t *testing.T) {
+	lowerMinParams(t)
+	data, _ := SealWithPassword([]byte("password"), nil, XChaCha20Poly1305, testParams)
+	if _, err := Open(testKey(), data); err != ErrPasswordRequired {
+		t.Errorf("expected ErrPasswordRequired, got %v", err)
+	}
+	data, _ = Seal(testKey(), nil, XChaCha20Poly1305)
+	if _, err := OpenWithPassword([]byte("password"), data, testLimits); err != ErrKeyRequired {
+		t.Errorf("expected ErrKeyRequired, got %v", err)
+	}
+}
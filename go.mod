@@ -1,8 +1,11 @@
 module ChaCha-Go
 
-go 1.17
+go 1.22
+
+require golang.org/x/crypto v0.33.0
 
 require (
 	golang.org/x/lint v0.0.0-20210508222113-6edffad5e616 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/tools v0.1.6 // indirect
 )
@@ -0,0 +1,165 @@
+/*
+Package dudect implements statistical timing-leak tests of the dudect method
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package dudect
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// The harness follows dudect (Reparaz, Balasch, Verbauwhede, "Dude, is my
+// code constant time?", 2017): the code is timed for two classes of inputs,
+// fixed and random, chosen at random for every measurement. Welch's t-test
+// compares the two distributions of times, for all measurements and for
+// measurements cropped at several percentiles (which removes the long tail
+// of interrupts and scheduling). A large |t| means the time depends on the
+// data. A small |t| doesn't prove constant time, only that no leak was seen.
+
+const (
+	// EnvVar enables the timing tests, they are slow and noisy
+	// so go test skips them unless the variable is set
+	EnvVar = "CHACHA_TIMING"
+
+	// Threshold of |t| above which the timing depends on the data,
+	// dudect reports "definitely not constant time" above 10
+	Threshold = 10.0
+
+	// Fixed and Random are the classes of inputs
+	Fixed  = 0
+	Random = 1
+)
+
+// Sink keeps results of the timed code, so the compiler
+// can't drop the code as unused
+var Sink bool
+
+// Enabled reports if the timing tests should run
+func Enabled() bool {
+	return os.Getenv(EnvVar) != ""
+}
+
+// SkipUnlessEnabled skips the timing test unless EnvVar is set
+func SkipUnlessEnabled(tb testing.TB) {
+	tb.Helper()
+	if !Enabled() {
+		tb.Skip("set " + EnvVar + "=1 to run timing tests")
+	}
+}
+
+// Result of the measurements
+type Result struct {
+	T            float64 // the t statistic with the largest |t| of all tests
+	Measurements int     // number of measurements of every test
+}
+
+// Leaks reports if the time depends on the class of inputs
+func (r Result) Leaks() bool {
+	return math.Abs(r.T) > Threshold
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("t = %.2f after %d measurements", r.T, r.Measurements)
+}
+
+// Run takes passed number of measurements. Before every measurement
+// prepare is called, outside of the timed part, with randomly chosen
+// class to set up inputs of f, then f is timed batch times in a row.
+// The first tenth of the measurements is discarded as warm-up.
+func Run(measurements, batch int, prepare func(class int), f func()) Result {
+	warmUp := measurements / 10
+	times := make([]float64, 0, measurements)
+	classes := make([]int, 0, measurements)
+	for i := 0; i < warmUp+measurements; i++ {
+		class := rand.IntN(2)
+		prepare(class)
+		start := time.Now()
+		for j := 0; j < batch; j++ {
+			f()
+		}
+		elapsed := time.Since(start)
+		if i >= warmUp {
+			times = append(times, float64(elapsed))
+			classes = append(classes, class)
+		}
+	}
+	return analyze(times, classes)
+}
+
+// analyze returns the t statistic with the largest |t|
+// of all measurements and measurements below the percentiles
+func analyze(times []float64, classes []int) Result {
+	sorted := slices.Clone(times)
+	slices.Sort(sorted)
+	limits := []float64{math.Inf(1)}
+	for _, p := range []float64{0.5, 0.75, 0.9, 0.95, 0.99} {
+		limits = append(limits, sorted[int(p*float64(len(sorted)-1))])
+	}
+
+	result := Result{Measurements: len(times)}
+	for _, limit := range limits {
+		var w welch
+		for i, x := range times {
+			if x <= limit {
+				w.add(classes[i], x)
+			}
+		}
+		if t := w.t(); math.Abs(t) > math.Abs(result.T) {
+			result.T = t
+		}
+	}
+	return result
+}
+
+// welch computes Welch's t-test with online mean
+// and variance (Welford's algorithm) of both classes
+type welch struct {
+	n, mean, m2 [2]float64
+}
+
+func (w *welch) add(class int, x float64) {
+	w.n[class]++
+	delta := x - w.mean[class]
+	w.mean[class] += delta / w.n[class]
+	w.m2[class] += delta * (x - w.mean[class])
+}
+
+func (w *welch) t() float64 {
+	if w.n[0] < 2 || w.n[1] < 2 {
+		return 0
+	}
+	variance0 := w.m2[0] / (w.n[0] - 1)
+	variance1 := w.m2[1] / (w.n[1] - 1)
+	denominator := math.Sqrt(variance0/w.n[0] + variance1/w.n[1])
+	if denominator == 0 {
+		return 0
+	}
+	return (w.mean[0] - w.mean[1]) / denominator
+}
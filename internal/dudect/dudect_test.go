@@ -0,0 +1,95 @@
+/*
+Package dudect implements statistical timing-leak tests of the dudect method
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package dudect
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_welch(t *testing.T) {
+	var w welch
+	for i := 0; i < 1000; i++ {
+		x := float64(i % 10)
+		w.add(Fixed, x)
+		w.add(Random, x)
+	}
+	if w.t() != 0 {
+		t.Errorf("the same distributions: t = %f", w.t())
+	}
+
+	w = welch{}
+	for i := 0; i < 1000; i++ {
+		x := float64(i % 10)
+		w.add(Fixed, x)
+		w.add(Random, x+1)
+	}
+	// means differ by 1, variances 9.17, t = -1/sqrt(2*9.17/1000)
+	if expected := -1 / math.Sqrt(2*w.m2[0]/999/1000); math.Abs(w.t()-expected) > 1e-9 || w.t() > -7 {
+		t.Errorf("shifted distributions: t = %f, expected %f", w.t(), expected)
+	}
+}
+
+func Test_analyze(t *testing.T) {
+	times := make([]float64, 0, 2000)
+	classes := make([]int, 0, 2000)
+	for i := 0; i < 1000; i++ {
+		times = append(times, float64(100+i%7), float64(100+i%7))
+		classes = append(classes, Fixed, Random)
+	}
+	if r := analyze(times, classes); r.Leaks() || r.Measurements != 2000 {
+		t.Errorf("no leak expected: %v", r)
+	}
+
+	// slower random class
+	for i := range times {
+		if classes[i] == Random {
+			times[i] += 3
+		}
+	}
+	if r := analyze(times, classes); !r.Leaks() {
+		t.Errorf("leak expected: %v", r)
+	}
+}
+
+// Test_Run checks that the harness flags an early-exit comparison
+func Test_Run(t *testing.T) {
+	SkipUnlessEnabled(t)
+	secret := make([]byte, 1024)
+	input := make([]byte, 1024)
+	result := Run(20000, 20, func(class int) {
+		copy(input, secret)
+		if class == Random {
+			input[0] ^= 1
+		}
+	}, func() {
+		Sink = string(input) == string(secret)
+	})
+	t.Log(result)
+	if !result.Leaks() {
+		t.Errorf("leak of early-exit comparison not detected: %v", result)
+	}
+}
@@ -0,0 +1,334 @@
+/*
+Package kat runs known-answer tests of all primitives from vector files in testdata
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package kat
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ChaCha-Go/chacha"
+	"ChaCha-Go/chacha20poly1305"
+	"ChaCha-Go/poly1305"
+	"ChaCha-Go/salsa20"
+	"ChaCha-Go/shared"
+)
+
+// vectorFile is a JSON file with known-answer vectors in testdata
+type vectorFile struct {
+	Source  string   `json:"source"`
+	Vectors []vector `json:"vectors"`
+}
+
+// vector is a known-answer vector, hex encoded
+type vector struct {
+	Name      string `json:"name"`
+	Primitive string `json:"primitive"`
+	Key       string `json:"key"`
+	Nonce     string `json:"nonce"`
+	Counter   uint64 `json:"counter"`
+	AAD       string `json:"aad"`
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+}
+
+// decoded are the fields of the vector as bytes
+type decoded struct {
+	key, nonce, aad, input, output []byte
+	counter                        uint64
+}
+
+// primitives checks a vector of the primitive, a vector
+// of a primitive not listed here fails the test
+var primitives = map[string]func(v *decoded) error{
+	"chacha20-block":    checkBlock,
+	"chacha20":          chachaChecker(chacha.NewCipher),
+	"chacha20-djb":      checkDJB,
+	"chacha12":          chachaChecker(chacha.NewChaCha12),
+	"chacha8":           chachaChecker(chacha.NewChaCha8),
+	"hchacha20":         checkHChaCha20,
+	"xchacha20":         chachaChecker(chacha.NewXCipher),
+	"poly1305":          checkPoly1305,
+	"poly1305-keygen":   checkKeyGen,
+	"chacha20poly1305":  aeadChecker(chacha20poly1305.New),
+	"xchacha20poly1305": aeadChecker(chacha20poly1305.NewX),
+	"salsa20":           salsaChecker(salsa20.NewCipher),
+	"salsa20-12":        salsaChecker(salsa20.NewSalsa2012),
+	"salsa20-8":         salsaChecker(salsa20.NewSalsa208),
+	"hsalsa20":          checkHSalsa20,
+	"xsalsa20":          salsaChecker(salsa20.NewXCipher),
+}
+
+func Test_Vectors(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no vector files: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vf vectorFile
+		if err := json.Unmarshal(data, &vf); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if len(vf.Vectors) == 0 {
+			t.Errorf("%s: no vectors", file)
+		}
+		for _, v := range vf.Vectors {
+			if err := runVector(&v); err != nil {
+				t.Errorf("%s, %s (%s): %v", filepath.Base(file), v.Name, v.Primitive, err)
+			}
+		}
+	}
+}
+
+func runVector(v *vector) error {
+	check, ok := primitives[v.Primitive]
+	if !ok {
+		return errors.New("unknown primitive")
+	}
+	var d decoded
+	fields := []struct {
+		value string
+		out   *[]byte
+	}{
+		{v.Key, &d.key}, {v.Nonce, &d.nonce}, {v.AAD, &d.aad}, {v.Input, &d.input}, {v.Output, &d.output},
+	}
+	for _, f := range fields {
+		b, err := hex.DecodeString(f.value)
+		if err != nil {
+			return err
+		}
+		*f.out = b
+	}
+	d.counter = v.Counter
+	return check(&d)
+}
+
+// compare returns an error if the result is not the expected output
+func compare(what string, got, expected []byte) error {
+	if !shared.AreByteSlicesEqual(got, expected) {
+		return fmt.Errorf("%s: got %x, expected %x", what, got, expected)
+	}
+	return nil
+}
+
+// counter32 returns the counter of a primitive with 32-bit block counter
+func (v *decoded) counter32() (uint32, error) {
+	if v.counter > math.MaxUint32 {
+		return 0, errors.New("counter above 32 bits")
+	}
+	return uint32(v.counter), nil
+}
+
+func checkBlock(v *decoded) error {
+	counter, err := v.counter32()
+	if err != nil {
+		return err
+	}
+	cc, err := chacha.NewCipher(v.key, v.nonce, counter)
+	if err != nil {
+		return err
+	}
+	block := chacha.Serialize(chacha.Block(cc.InitState(counter)))
+	if err := compare("Block", block, v.output); err != nil {
+		return err
+	}
+	return compare("Cipher", cc.Cipher(make([]byte, len(v.output))), v.output)
+}
+
+// chachaChecker returns a checker of input encrypted to output
+// by the cipher objects of passed constructor
+func chachaChecker(newCipher func(key, nonce []byte, blockCount uint32) (*chacha.ChaCha, error)) func(v *decoded) error {
+	return func(v *decoded) error {
+		counter, err := v.counter32()
+		if err != nil {
+			return err
+		}
+		cc, err := newCipher(v.key, v.nonce, counter)
+		if err != nil {
+			return err
+		}
+		return checkChaCha(cc, v)
+	}
+}
+
+// checkDJB checks the original variant with 64-bit counter
+func checkDJB(v *decoded) error {
+	cc, err := chacha.NewDJBCipher(v.key, v.nonce, v.counter)
+	if err != nil {
+		return err
+	}
+	return checkChaCha(cc, v)
+}
+
+func checkChaCha(cc *chacha.ChaCha, v *decoded) error {
+	if err := compare("Cipher", cc.Cipher(v.input), v.output); err != nil {
+		return err
+	}
+	if err := compare("CipherAsync", cc.CipherAsync(v.input), v.output); err != nil {
+		return err
+	}
+
+	// byte by byte through the stream
+	out := make([]byte, len(v.input))
+	s := cc.NewStream()
+	for i := range v.input {
+		s.XORKeyStream(out[i:i+1], v.input[i:i+1])
+	}
+	if err := compare("Stream", out, v.output); err != nil {
+		return err
+	}
+
+	// the tail from the middle of a block
+	if len(v.input) > 1 {
+		half := len(v.input) / 2
+		cc.XORKeyStreamAt(out[half:], v.input[half:], uint64(half))
+		if err := compare("XORKeyStreamAt", out[half:], v.output[half:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkHChaCha20(v *decoded) error {
+	return compare("HChaCha20", chacha.HChaCha20(v.key, v.nonce), v.output)
+}
+
+func checkPoly1305(v *decoded) error {
+	if err := compare("Sum", poly1305.Sum(v.input, v.key), v.output); err != nil {
+		return err
+	}
+	// written in uneven pieces
+	m := poly1305.New(v.key)
+	for input, n := v.input, 1; len(input) > 0; n += 3 {
+		n = min(n, len(input))
+		m.Write(input[:n])
+		input = input[n:]
+	}
+	if err := compare("MAC", m.Sum(nil), v.output); err != nil {
+		return err
+	}
+	if !poly1305.Verify(v.output, v.input, v.key) {
+		return errors.New("Verify rejected the tag")
+	}
+	return nil
+}
+
+// checkKeyGen checks the one-time Poly1305 key, the first
+// 32 bytes of the block with counter 0 (RFC 8439, section 2.6)
+func checkKeyGen(v *decoded) error {
+	cc, err := chacha.NewCipher(v.key, v.nonce, 0)
+	if err != nil {
+		return err
+	}
+	key := chacha.Serialize(chacha.Block(cc.InitState(0)))[:poly1305.KeySize]
+	return compare("one-time key", key, v.output)
+}
+
+// aeadChecker returns a checker of input sealed with aad
+// to output by the AEAD of passed constructor
+func aeadChecker(newAEAD func(key []byte) (cipher.AEAD, error)) func(v *decoded) error {
+	return func(v *decoded) error {
+		aead, err := newAEAD(v.key)
+		if err != nil {
+			return err
+		}
+		if err := compare("Seal", aead.Seal(nil, v.nonce, v.input, v.aad), v.output); err != nil {
+			return err
+		}
+		plaintext, err := aead.Open(nil, v.nonce, v.output, v.aad)
+		if err != nil {
+			return err
+		}
+		if err := compare("Open", plaintext, v.input); err != nil {
+			return err
+		}
+
+		modified := append([]byte(nil), v.output...)
+		modified[len(modified)-1] ^= 0x01
+		if _, err := aead.Open(nil, v.nonce, modified, v.aad); err == nil {
+			return errors.New("Open accepted modified tag")
+		}
+		return nil
+	}
+}
+
+// salsaChecker returns a checker of input encrypted to output
+// by the cipher objects of passed constructor
+func salsaChecker(newCipher func(key, nonce []byte, blockCount uint64) (*salsa20.Salsa20, error)) func(v *decoded) error {
+	return func(v *decoded) error {
+		s, err := newCipher(v.key, v.nonce, v.counter)
+		if err != nil {
+			return err
+		}
+		if err := compare("Cipher", s.Cipher(v.input), v.output); err != nil {
+			return err
+		}
+
+		// byte by byte through the stream
+		out := make([]byte, len(v.input))
+		st := s.NewStream()
+		for i := range v.input {
+			st.XORKeyStream(out[i:i+1], v.input[i:i+1])
+		}
+		if err := compare("Stream", out, v.output); err != nil {
+			return err
+		}
+
+		// the tail from the middle of a block
+		if len(v.input) > 1 {
+			half := len(v.input) / 2
+			s.XORKeyStreamAt(out[half:], v.input[half:], uint64(half))
+			if err := compare("XORKeyStreamAt", out[half:], v.output[half:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func checkHSalsa20(v *decoded) error {
+	return compare("HSalsa20", salsa20.HSalsa20(v.key, v.nonce), v.output)
+}
+
+func Test_UnknownPrimitive(t *testing.T) {
+	if err := runVector(&vector{Name: "unknown", Primitive: "chacha21"}); err == nil {
+		t.Error("vector of unknown primitive passed")
+	}
+	v := &vector{Name: "wrong output", Primitive: "poly1305", Key: hex.EncodeToString(make([]byte, 32)), Output: "00"}
+	if err := runVector(v); err == nil {
+		t.Error("vector with wrong output passed")
+	}
+}
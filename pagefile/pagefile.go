@@ -0,0 +1,395 @@
+/*
+Package pagefile implements random-access encrypted files with authenticated pages
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package pagefile
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"ChaCha-Go/chacha"
+	"ChaCha-Go/chacha20poly1305"
+)
+
+// Layout of the file:
+//
+//	header     76 bytes
+//	page 0     nonce (24 bytes) || ciphertext (page size) || tag (16 bytes)
+//	page 1     ...
+//
+// Layout of the header (version 1):
+//
+//	magic       4 bytes  "CCPF"
+//	version     1 byte   1
+//	reserved    3 bytes  zero
+//	page size   4 bytes  little endian
+//	file id    16 bytes  random
+//	size        8 bytes  plaintext size, little endian
+//	nonce      24 bytes  random XChaCha20 nonce
+//	tag        16 bytes  XChaCha20-Poly1305 tag of the fields above
+//
+// Every page is sealed with XChaCha20-Poly1305 under a fresh random nonce
+// each time it's written. The file id and the page index are the additional
+// data, so pages can't be moved to another position or another file. The
+// last page is padded with zeros. Pages beyond the size are ignored.
+//
+// Replacing the whole file, the header or a page with an older version
+// of itself (rollback) is not detected.
+const (
+	Version1        = 1
+	HeaderSize      = 36 + chacha20poly1305.NonceSizeX + chacha20poly1305.Overhead // in bytes
+	DefaultPageSize = 4096                                                         // in bytes
+	MinPageSize     = 512                                                          // in bytes
+	MaxPageSize     = 1024 * 1024                                                  // in bytes
+
+	pageOverhead = chacha20poly1305.NonceSizeX + chacha20poly1305.Overhead // in bytes
+)
+
+var magic = []byte("CCPF")
+
+var (
+	// ErrFormat is returned for storage which doesn't start with a valid header
+	ErrFormat = errors.New("pagefile: invalid header")
+	// ErrAuthentication is returned if the header or a page
+	// was modified or is missing, or the key is wrong
+	ErrAuthentication = errors.New("pagefile: authentication failed")
+	// ErrOffset is returned for negative offsets and sizes
+	ErrOffset = errors.New("pagefile: invalid offset")
+)
+
+// PageSizeError is returned for page size out of range
+type PageSizeError int
+
+func (p PageSizeError) Error() string {
+	return "pagefile: invalid page size " + strconv.Itoa(int(p))
+}
+
+// Storage keeps the encrypted file, *os.File implements it
+type Storage interface {
+	io.ReaderAt
+	io.WriterAt
+	Truncate(size int64) error
+	Sync() error
+}
+
+// File is an encrypted file with random access. Reading decrypts and
+// authenticates only the pages which contain the requested range, writing
+// re-encrypts them. It's safe for concurrent use.
+type File struct {
+	mu       sync.RWMutex
+	storage  Storage
+	aead     cipher.AEAD
+	pageSize int
+	fileID   [16]byte
+	size     int64
+}
+
+// Create initializes the storage as an empty encrypted file
+// with passed 256-bit key and page size, existing content is discarded
+func Create(storage Storage, key []byte, pageSize int) (*File, error) {
+	if pageSize < MinPageSize || pageSize > MaxPageSize {
+		return nil, PageSizeError(pageSize)
+	}
+	f, err := newFile(storage, key, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(f.fileID[:]); err != nil {
+		return nil, err
+	}
+	if err := storage.Truncate(0); err != nil {
+		return nil, err
+	}
+	if err := f.writeHeader(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Open opens the encrypted file created by Create. It returns
+// ErrAuthentication if the header was modified or the key is wrong.
+func Open(storage Storage, key []byte) (*File, error) {
+	header := make([]byte, HeaderSize)
+	if n, err := storage.ReadAt(header, 0); n < HeaderSize {
+		if err == nil || err == io.EOF {
+			err = ErrFormat
+		}
+		return nil, err
+	}
+	if !bytes.Equal(header[:4], magic) || header[4] != Version1 {
+		return nil, ErrFormat
+	}
+	pageSize := int(binary.LittleEndian.Uint32(header[8:]))
+	if pageSize < MinPageSize || pageSize > MaxPageSize {
+		return nil, ErrFormat
+	}
+
+	f, err := newFile(storage, key, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.aead.Open(nil, header[36:60], header[60:], header[:36]); err != nil {
+		return nil, ErrAuthentication
+	}
+	copy(f.fileID[:], header[12:28])
+	f.size = int64(binary.LittleEndian.Uint64(header[28:]))
+	if f.size < 0 {
+		return nil, ErrFormat
+	}
+	return f, nil
+}
+
+func newFile(storage Storage, key []byte, pageSize int) (*File, error) {
+	if len(key) != chacha.KeySize {
+		return nil, chacha.KeySizeError(len(key))
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	return &File{storage: storage, aead: aead, pageSize: pageSize}, nil
+}
+
+// PageSize returns the size of the pages, in bytes
+func (f *File) PageSize() int {
+	return f.pageSize
+}
+
+// Size returns the size of the plaintext, in bytes
+func (f *File) Size() int64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.size
+}
+
+// ReadAt reads len(p) bytes of the plaintext from offset off.
+// It returns io.EOF if fewer bytes are read because the end of file
+// was reached, and ErrAuthentication if a page was modified.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrOffset
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if off >= f.size {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > f.size || end < off {
+		end = f.size
+	}
+
+	n := 0
+	page := make([]byte, f.pageSize)
+	for pos := off; pos < end; {
+		index := pos / int64(f.pageSize)
+		if err := f.readPage(index, page); err != nil {
+			return n, err
+		}
+		lo := pos - index*int64(f.pageSize)
+		hi := min(end-index*int64(f.pageSize), int64(f.pageSize))
+		n += copy(p[n:], page[lo:hi])
+		pos += hi - lo
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt writes p to the plaintext at offset off.
+// Writing beyond the end of file extends it, the gap reads as zeros.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	end := off + int64(len(p))
+	if off < 0 || end < off {
+		return 0, ErrOffset
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+	page := make([]byte, f.pageSize)
+	if err := f.fillPages(off / int64(f.pageSize)); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	pages := f.pages(f.size)
+	for pos := off; pos < end; {
+		index := pos / int64(f.pageSize)
+		lo := pos - index*int64(f.pageSize)
+		hi := min(end-index*int64(f.pageSize), int64(f.pageSize))
+		if (lo > 0 || hi < int64(f.pageSize)) && index < pages {
+			if err := f.readPage(index, page); err != nil {
+				return n, err
+			}
+		} else {
+			clear(page)
+		}
+		copy(page[lo:hi], p[n:])
+		if err := f.writePage(index, page); err != nil {
+			return n, err
+		}
+		n += int(hi - lo)
+		pos += hi - lo
+	}
+
+	if end > f.size {
+		f.size = end
+		if err := f.writeHeader(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Truncate changes the size of the plaintext. Extending
+// the file adds zeros, shrinking it removes the pages past
+// the new end from the storage.
+func (f *File) Truncate(size int64) error {
+	if size < 0 {
+		return ErrOffset
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if size >= f.size {
+		if err := f.fillPages(f.pages(size)); err != nil {
+			return err
+		}
+		f.size = size
+		return f.writeHeader()
+	}
+
+	// the padding of the last page must stay zero
+	if tail := size % int64(f.pageSize); tail != 0 {
+		index := size / int64(f.pageSize)
+		page := make([]byte, f.pageSize)
+		if err := f.readPage(index, page); err != nil {
+			return err
+		}
+		clear(page[tail:])
+		if err := f.writePage(index, page); err != nil {
+			return err
+		}
+	}
+	f.size = size
+	if err := f.writeHeader(); err != nil {
+		return err
+	}
+	return f.storage.Truncate(f.offset(f.pages(size)))
+}
+
+// Sync commits the storage, see os.File.Sync
+func (f *File) Sync() error {
+	return f.storage.Sync()
+}
+
+// pages returns the number of pages of plaintext of passed size
+func (f *File) pages(size int64) int64 {
+	return (size + int64(f.pageSize) - 1) / int64(f.pageSize)
+}
+
+// offset returns position of the page in the storage
+func (f *File) offset(index int64) int64 {
+	return HeaderSize + index*int64(f.pageSize+pageOverhead)
+}
+
+// fillPages writes zero pages from the end of file up to passed page
+func (f *File) fillPages(end int64) error {
+	zero := make([]byte, f.pageSize)
+	for index := f.pages(f.size); index < end; index++ {
+		if err := f.writePage(index, zero); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageData returns additional data of the page
+func (f *File) pageData(index int64) []byte {
+	data := make([]byte, len(f.fileID)+8)
+	copy(data, f.fileID[:])
+	binary.LittleEndian.PutUint64(data[len(f.fileID):], uint64(index))
+	return data
+}
+
+// readPage reads, authenticates and decrypts the page
+func (f *File) readPage(index int64, out []byte) error {
+	slot := make([]byte, f.pageSize+pageOverhead)
+	if n, err := f.storage.ReadAt(slot, f.offset(index)); n < len(slot) {
+		if err == nil || err == io.EOF {
+			err = ErrAuthentication
+		}
+		return err
+	}
+	nonce := slot[:chacha20poly1305.NonceSizeX]
+	if _, err := f.aead.Open(out[:0], nonce, slot[len(nonce):], f.pageData(index)); err != nil {
+		return ErrAuthentication
+	}
+	return nil
+}
+
+// writePage encrypts the page with a fresh nonce and writes it
+func (f *File) writePage(index int64, page []byte) error {
+	slot := make([]byte, chacha20poly1305.NonceSizeX, f.pageSize+pageOverhead)
+	if _, err := rand.Read(slot); err != nil {
+		return err
+	}
+	slot = f.aead.Seal(slot, slot, page, f.pageData(index))
+	_, err := f.storage.WriteAt(slot, f.offset(index))
+	return err
+}
+
+// writeHeader writes the header with the current size
+func (f *File) writeHeader() error {
+	header := make([]byte, 36, HeaderSize)
+	copy(header, magic)
+	header[4] = Version1
+	binary.LittleEndian.PutUint32(header[8:], uint32(f.pageSize))
+	copy(header[12:], f.fileID[:])
+	binary.LittleEndian.PutUint64(header[28:], uint64(f.size))
+
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	header = append(header, nonce...)
+	header = f.aead.Seal(header, nonce, nil, header[:36])
+	_, err := f.storage.WriteAt(header, 0)
+	return err
+}
@@ -0,0 +1,232 @@
+/*
+Package pagefile implements random-access encrypted files with authenticated pages
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package pagefile
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ChaCha-Go/chacha"
+	"ChaCha-Go/shared"
+)
+
+func testKey() []byte {
+	key := make([]byte, chacha.KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+// createFiles creates encrypted file and plaintext reference file
+func createFiles(t *testing.T, pageSize int) (*File, *os.File, *os.File) {
+	dir := t.TempDir()
+	storage, err := os.Create(filepath.Join(dir, "encrypted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference, err := os.Create(filepath.Join(dir, "reference"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		storage.Close()
+		reference.Close()
+	})
+	f, err := Create(storage, testKey(), pageSize)
+	if err != nil {
+		t.Fatalf("Create returned %v", err)
+	}
+	return f, storage, reference
+}
+
+// compareRead reads the same range from both files
+func compareRead(t *testing.T, f *File, reference *os.File, off int64, n int) {
+	t.Helper()
+	expected := make([]byte, n)
+	expectedN, expectedErr := reference.ReadAt(expected, off)
+	got := make([]byte, n)
+	gotN, gotErr := f.ReadAt(got, off)
+	if gotN != expectedN || gotErr != expectedErr || !shared.AreByteSlicesEqual(got[:gotN], expected[:expectedN]) {
+		t.Fatalf("ReadAt(%d, %d): got %d, %v, expected %d, %v", n, off, gotN, gotErr, expectedN, expectedErr)
+	}
+}
+
+func Test_RandomWorkload(t *testing.T) {
+	const pageSize = MinPageSize
+	f, storage, reference := createFiles(t, pageSize)
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 2000; i++ {
+		off := rng.Int64N(10 * pageSize)
+		n := rng.IntN(3 * pageSize)
+		switch op := rng.IntN(10); {
+		case op < 5:
+			data := make([]byte, n)
+			for j := range data {
+				data[j] = byte(rng.Uint32())
+			}
+			if _, err := reference.WriteAt(data, off); err != nil {
+				t.Fatal(err)
+			}
+			if written, err := f.WriteAt(data, off); written != n || err != nil {
+				t.Fatalf("WriteAt(%d, %d) returned %d, %v", n, off, written, err)
+			}
+		case op < 9:
+			compareRead(t, f, reference, off, n)
+		default:
+			if err := reference.Truncate(off); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Truncate(off); err != nil {
+				t.Fatalf("Truncate(%d) returned %v", off, err)
+			}
+		}
+		if info, _ := reference.Stat(); f.Size() != info.Size() {
+			t.Fatalf("operation %d: size %d, expected %d", i, f.Size(), info.Size())
+		}
+	}
+
+	// the same content after reopening
+	if err := f.Sync(); err != nil {
+		t.Fatalf("Sync returned %v", err)
+	}
+	f, err := Open(storage, testKey())
+	if err != nil {
+		t.Fatalf("Open returned %v", err)
+	}
+	compareRead(t, f, reference, 0, int(f.Size())+1)
+	if info, _ := storage.Stat(); info.Size() != HeaderSize+f.pages(f.Size())*int64(pageSize+pageOverhead) {
+		t.Errorf("invalid storage size %d for %d bytes", info.Size(), f.Size())
+	}
+}
+
+func Test_Truncate(t *testing.T) {
+	f, _, reference := createFiles(t, MinPageSize)
+	data := make([]byte, 3*MinPageSize)
+	for i := range data {
+		data[i] = 0xff
+	}
+	f.WriteAt(data, 0)
+	reference.WriteAt(data, 0)
+
+	// shrinking and growing again must give zeros
+	for _, size := range []int64{MinPageSize + 10, 3*MinPageSize + 7, 5, 0, 2 * MinPageSize} {
+		f.Truncate(size)
+		reference.Truncate(size)
+		compareRead(t, f, reference, 0, 4*MinPageSize)
+	}
+}
+
+func Test_Tampering(t *testing.T) {
+	const pageSize = MinPageSize
+	f, storage, _ := createFiles(t, pageSize)
+	data := make([]byte, 3*pageSize)
+	f.WriteAt(data, 0)
+	buffer := make([]byte, len(data))
+
+	// modified page
+	page1 := make([]byte, pageSize+pageOverhead)
+	storage.ReadAt(page1, f.offset(1))
+	storage.WriteAt([]byte{page1[100] ^ 1}, f.offset(1)+100)
+	if _, err := f.ReadAt(buffer[:10], pageSize); err != ErrAuthentication {
+		t.Errorf("modified page: expected ErrAuthentication, got %v", err)
+	}
+	if _, err := f.ReadAt(buffer[:10], 0); err != nil {
+		t.Errorf("not modified page returned %v", err)
+	}
+
+	// page moved to another position
+	storage.WriteAt(page1, f.offset(2))
+	storage.WriteAt(page1, f.offset(1))
+	if _, err := f.ReadAt(buffer[:10], 2*pageSize); err != ErrAuthentication {
+		t.Errorf("moved page: expected ErrAuthentication, got %v", err)
+	}
+
+	// missing page
+	storage.Truncate(f.offset(2))
+	if _, err := f.ReadAt(buffer, 0); err != ErrAuthentication {
+		t.Errorf("missing page: expected ErrAuthentication, got %v", err)
+	}
+
+	// modified size in the header
+	storage.WriteAt([]byte{1}, 28)
+	if _, err := Open(storage, testKey()); err != ErrAuthentication {
+		t.Errorf("modified header: expected ErrAuthentication, got %v", err)
+	}
+}
+
+func Test_OpenErrors(t *testing.T) {
+	f, storage, _ := createFiles(t, DefaultPageSize)
+	f.WriteAt([]byte("data"), 0)
+
+	wrongKey := testKey()
+	wrongKey[0] ^= 1
+	if _, err := Open(storage, wrongKey); err != ErrAuthentication {
+		t.Errorf("wrong key: expected ErrAuthentication, got %v", err)
+	}
+	var keySizeErr chacha.KeySizeError
+	if _, err := Open(storage, make([]byte, 16)); !errors.As(err, &keySizeErr) {
+		t.Errorf("expected KeySizeError, got %v", err)
+	}
+	storage.Truncate(HeaderSize - 1)
+	if _, err := Open(storage, testKey()); err != ErrFormat {
+		t.Errorf("short header: expected ErrFormat, got %v", err)
+	}
+	storage.WriteAt([]byte("not an encrypted file at all, not an encrypted file at all, not an encrypted file"), 0)
+	if _, err := Open(storage, testKey()); err != ErrFormat {
+		t.Errorf("expected ErrFormat, got %v", err)
+	}
+
+	var pageSizeErr PageSizeError
+	if _, err := Create(storage, testKey(), MinPageSize-1); !errors.As(err, &pageSizeErr) {
+		t.Errorf("expected PageSizeError, got %v", err)
+	}
+	if _, err := f.ReadAt(nil, -1); err != ErrOffset {
+		t.Errorf("negative offset: expected ErrOffset, got %v", err)
+	}
+	if _, err := f.WriteAt(nil, -1); err != ErrOffset {
+		t.Errorf("negative offset: expected ErrOffset, got %v", err)
+	}
+	if _, err := f.ReadAt(make([]byte, 1), 100); err != io.EOF {
+		t.Errorf("read past end: expected io.EOF, got %v", err)
+	}
+}
+
+func BenchmarkWriteAt(b *testing.B) {
+	storage, _ := os.Create(filepath.Join(b.TempDir(), "encrypted"))
+	defer storage.Close()
+	f, _ := Create(storage, testKey(), DefaultPageSize)
+	data := make([]byte, 100)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		f.WriteAt(data, int64(i%1000)*1000)
+	}
+}
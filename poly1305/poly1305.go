@@ -0,0 +1,261 @@
+/*
+Package poly1305 implements Poly1305 one-time authenticator
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package poly1305
+
+import (
+	"crypto/subtle"
+)
+
+const (
+	KeySize   = 32 // in bytes
+	TagSize   = 16 // in bytes
+	blockSize = 16 // in bytes
+)
+
+const mask26 uint32 = 0x3ffffff
+
+// MAC object declaration.
+// The key must be used for one message only (RFC 8439, section 2.5).
+type MAC struct {
+	r        [5]uint32 // clamped 'r' part of the key, 5 x 26 bits
+	s        [4]uint32 // 's' part of the key, 4 x uint32
+	h        [5]uint32 // accumulator, 5 x 26 bits
+	buffer   [blockSize]byte
+	leftover int // number of bytes waiting in buffer
+}
+
+// New creates new MAC object for passed 32-byte one-time key
+func New(key []byte) *MAC {
+	if len(key) != KeySize {
+		panic("poly1305: invalid key size")
+	}
+
+	m := new(MAC)
+	// r &= 0xffffffc0ffffffc0ffffffc0fffffff (clamp)
+	m.r[0] = bytes2word(key[0:]) & 0x3ffffff
+	m.r[1] = (bytes2word(key[3:]) >> 2) & 0x3ffff03
+	m.r[2] = (bytes2word(key[6:]) >> 4) & 0x3ffc0ff
+	m.r[3] = (bytes2word(key[9:]) >> 6) & 0x3f03fff
+	m.r[4] = (bytes2word(key[12:]) >> 8) & 0x00fffff
+
+	m.s[0] = bytes2word(key[16:])
+	m.s[1] = bytes2word(key[20:])
+	m.s[2] = bytes2word(key[24:])
+	m.s[3] = bytes2word(key[28:])
+	return m
+}
+
+// Sum computes tag of passed message with one-time key
+func Sum(msg, key []byte) []byte {
+	m := New(key)
+	m.Write(msg)
+	return m.Sum(nil)
+}
+
+// Verify checks in constant time if passed tag
+// authenticates message with one-time key
+func Verify(tag, msg, key []byte) bool {
+	m := New(key)
+	m.Write(msg)
+	return m.Verify(tag)
+}
+
+// Write adds passed bytes to the authenticated message.
+// It never returns an error.
+func (m *MAC) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if m.leftover > 0 {
+		k := copy(m.buffer[m.leftover:], p)
+		m.leftover += k
+		p = p[k:]
+		if m.leftover < blockSize {
+			return n, nil
+		}
+		m.block(m.buffer[:], 1<<24)
+		m.leftover = 0
+	}
+	for len(p) >= blockSize {
+		m.block(p, 1<<24)
+		p = p[blockSize:]
+	}
+	if len(p) > 0 {
+		m.leftover = copy(m.buffer[:], p)
+	}
+	return n, nil
+}
+
+// Sum appends the tag of data written so far to b.
+// It does not change the state of the MAC.
+func (m *MAC) Sum(b []byte) []byte {
+	tmp := *m
+	tag := tmp.finish()
+	return append(b, tag[:]...)
+}
+
+// Verify checks in constant time if expected tag
+// authenticates data written so far
+func (m *MAC) Verify(expected []byte) bool {
+	tmp := *m
+	tag := tmp.finish()
+	return subtle.ConstantTimeCompare(tag[:], expected) == 1
+}
+
+// block adds one 16-byte block to the accumulator and multiplies it by r.
+// hibit is 1<<24 for full blocks and 0 for the padded final block.
+func (m *MAC) block(data []byte, hibit uint32) {
+	r0, r1, r2, r3, r4 := m.r[0], m.r[1], m.r[2], m.r[3], m.r[4]
+	s1, s2, s3, s4 := r1*5, r2*5, r3*5, r4*5
+	h0, h1, h2, h3, h4 := m.h[0], m.h[1], m.h[2], m.h[3], m.h[4]
+
+	// h += m[i]
+	h0 += bytes2word(data[0:]) & mask26
+	h1 += (bytes2word(data[3:]) >> 2) & mask26
+	h2 += (bytes2word(data[6:]) >> 4) & mask26
+	h3 += (bytes2word(data[9:]) >> 6) & mask26
+	h4 += (bytes2word(data[12:]) >> 8) | hibit
+
+	// h *= r
+	d0 := uint64(h0)*uint64(r0) + uint64(h1)*uint64(s4) + uint64(h2)*uint64(s3) + uint64(h3)*uint64(s2) + uint64(h4)*uint64(s1)
+	d1 := uint64(h0)*uint64(r1) + uint64(h1)*uint64(r0) + uint64(h2)*uint64(s4) + uint64(h3)*uint64(s3) + uint64(h4)*uint64(s2)
+	d2 := uint64(h0)*uint64(r2) + uint64(h1)*uint64(r1) + uint64(h2)*uint64(r0) + uint64(h3)*uint64(s4) + uint64(h4)*uint64(s3)
+	d3 := uint64(h0)*uint64(r3) + uint64(h1)*uint64(r2) + uint64(h2)*uint64(r1) + uint64(h3)*uint64(r0) + uint64(h4)*uint64(s4)
+	d4 := uint64(h0)*uint64(r4) + uint64(h1)*uint64(r3) + uint64(h2)*uint64(r2) + uint64(h3)*uint64(r1) + uint64(h4)*uint64(r0)
+
+	// (partial) h %= p
+	c := uint32(d0 >> 26)
+	h0 = uint32(d0) & mask26
+	d1 += uint64(c)
+	c = uint32(d1 >> 26)
+	h1 = uint32(d1) & mask26
+	d2 += uint64(c)
+	c = uint32(d2 >> 26)
+	h2 = uint32(d2) & mask26
+	d3 += uint64(c)
+	c = uint32(d3 >> 26)
+	h3 = uint32(d3) & mask26
+	d4 += uint64(c)
+	c = uint32(d4 >> 26)
+	h4 = uint32(d4) & mask26
+	h0 += c * 5
+	c = h0 >> 26
+	h0 &= mask26
+	h1 += c
+
+	m.h[0], m.h[1], m.h[2], m.h[3], m.h[4] = h0, h1, h2, h3, h4
+}
+
+func (m *MAC) finish() [TagSize]byte {
+	if m.leftover > 0 {
+		m.buffer[m.leftover] = 1
+		for i := m.leftover + 1; i < blockSize; i++ {
+			m.buffer[i] = 0
+		}
+		m.block(m.buffer[:], 0)
+	}
+
+	h0, h1, h2, h3, h4 := m.h[0], m.h[1], m.h[2], m.h[3], m.h[4]
+
+	// fully carry h
+	c := h1 >> 26
+	h1 &= mask26
+	h2 += c
+	c = h2 >> 26
+	h2 &= mask26
+	h3 += c
+	c = h3 >> 26
+	h3 &= mask26
+	h4 += c
+	c = h4 >> 26
+	h4 &= mask26
+	h0 += c * 5
+	c = h0 >> 26
+	h0 &= mask26
+	h1 += c
+
+	// compute g = h + -p
+	g0 := h0 + 5
+	c = g0 >> 26
+	g0 &= mask26
+	g1 := h1 + c
+	c = g1 >> 26
+	g1 &= mask26
+	g2 := h2 + c
+	c = g2 >> 26
+	g2 &= mask26
+	g3 := h3 + c
+	c = g3 >> 26
+	g3 &= mask26
+	g4 := h4 + c - (1 << 26)
+
+	// select h if h < p, or g otherwise (without branches)
+	mask := (g4 >> 31) - 1
+	g0 &= mask
+	g1 &= mask
+	g2 &= mask
+	g3 &= mask
+	g4 &= mask
+	mask = ^mask
+	h0 = (h0 & mask) | g0
+	h1 = (h1 & mask) | g1
+	h2 = (h2 & mask) | g2
+	h3 = (h3 & mask) | g3
+	h4 = (h4 & mask) | g4
+
+	// h = h % 2^128
+	h0 = h0 | (h1 << 26)
+	h1 = (h1 >> 6) | (h2 << 20)
+	h2 = (h2 >> 12) | (h3 << 14)
+	h3 = (h3 >> 18) | (h4 << 8)
+
+	// tag = (h + s) % 2^128
+	f := uint64(h0) + uint64(m.s[0])
+	h0 = uint32(f)
+	f = uint64(h1) + uint64(m.s[1]) + (f >> 32)
+	h1 = uint32(f)
+	f = uint64(h2) + uint64(m.s[2]) + (f >> 32)
+	h2 = uint32(f)
+	f = uint64(h3) + uint64(m.s[3]) + (f >> 32)
+	h3 = uint32(f)
+
+	var tag [TagSize]byte
+	word2bytes(tag[0:], h0)
+	word2bytes(tag[4:], h1)
+	word2bytes(tag[8:], h2)
+	word2bytes(tag[12:], h3)
+	return tag
+}
+
+func bytes2word(data []byte) uint32 {
+	return (uint32(data[3]) << 24) | (uint32(data[2]) << 16) | (uint32(data[1]) << 8) | uint32(data[0])
+}
+
+func word2bytes(out []byte, w uint32) {
+	out[3] = byte((w >> 24) & 0xff)
+	out[2] = byte((w >> 16) & 0xff)
+	out[1] = byte((w >> 8) & 0xff)
+	out[0] = byte(w & 0xff)
+}
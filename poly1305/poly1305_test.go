@@ -0,0 +1,236 @@
+/*
+Package poly1305 implements Poly1305 one-time authenticator
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package poly1305
+
+import (
+	"encoding/hex"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"ChaCha-Go/internal/dudect"
+	"ChaCha-Go/shared"
+)
+
+func fromHex(s string) []byte {
+	data, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+const ietfContribution = "Any submission to the IETF intended by the Contributor for publication as all or part of an IETF Internet-Draft or RFC and any statement made within the context of an IETF activity is considered an \"IETF Contribution\". Such statements include oral statements in IETF sessions, as well as written and electronic communications made at any time or place, which are addressed to"
+
+var testVectors = []struct {
+	name string
+	key  []byte
+	msg  []byte
+	tag  []byte
+}{
+	{
+		name: "RFC 8439 2.5.2",
+		key:  fromHex("85d6be7857556d337f4452fe42d506a8 0103808afb0db2fd4abff6af4149f51b"),
+		msg:  []byte("Cryptographic Forum Research Group"),
+		tag:  fromHex("a8061dc1305136c6c22b8baf0c0127a9"),
+	},
+	{
+		name: "RFC 8439 A.3 #1",
+		key:  make([]byte, 32),
+		msg:  make([]byte, 64),
+		tag:  make([]byte, 16),
+	},
+	{
+		name: "RFC 8439 A.3 #2",
+		key:  fromHex("00000000000000000000000000000000 36e5f6b5c5e06070f0efca96227a863e"),
+		msg:  []byte(ietfContribution),
+		tag:  fromHex("36e5f6b5c5e06070f0efca96227a863e"),
+	},
+	{
+		name: "RFC 8439 A.3 #3",
+		key:  fromHex("36e5f6b5c5e06070f0efca96227a863e 00000000000000000000000000000000"),
+		msg:  []byte(ietfContribution),
+		tag:  fromHex("f3477e7cd95417af89a6b8794c310cf0"),
+	},
+	{
+		name: "RFC 8439 A.3 #4",
+		key:  fromHex("1c9240a5eb55d38af333888604f6b5f0 473917c1402b80099dca5cbc207075c0"),
+		msg:  []byte("'Twas brillig, and the slithy toves\nDid gyre and gimble in the wabe:\nAll mimsy were the borogoves,\nAnd the mome raths outgrabe."),
+		tag:  fromHex("4541669a7eaaee61e708dc7cbcc5eb62"),
+	},
+	{
+		name: "RFC 8439 A.3 #5",
+		key:  fromHex("02000000000000000000000000000000 00000000000000000000000000000000"),
+		msg:  fromHex("ffffffffffffffffffffffffffffffff"),
+		tag:  fromHex("03000000000000000000000000000000"),
+	},
+	{
+		name: "RFC 8439 A.3 #6",
+		key:  fromHex("02000000000000000000000000000000 ffffffffffffffffffffffffffffffff"),
+		msg:  fromHex("02000000000000000000000000000000"),
+		tag:  fromHex("03000000000000000000000000000000"),
+	},
+	{
+		name: "RFC 8439 A.3 #7",
+		key:  fromHex("01000000000000000000000000000000 00000000000000000000000000000000"),
+		msg: fromHex("ffffffffffffffffffffffffffffffff" +
+			"f0ffffffffffffffffffffffffffffff" +
+			"11000000000000000000000000000000"),
+		tag: fromHex("05000000000000000000000000000000"),
+	},
+	{
+		name: "RFC 8439 A.3 #8",
+		key:  fromHex("01000000000000000000000000000000 00000000000000000000000000000000"),
+		msg: fromHex("ffffffffffffffffffffffffffffffff" +
+			"fbfefefefefefefefefefefefefefefe" +
+			"01010101010101010101010101010101"),
+		tag: fromHex("00000000000000000000000000000000"),
+	},
+	{
+		name: "RFC 8439 A.3 #9",
+		key:  fromHex("02000000000000000000000000000000 00000000000000000000000000000000"),
+		msg:  fromHex("fdffffffffffffffffffffffffffffff"),
+		tag:  fromHex("faffffffffffffffffffffffffffffff"),
+	},
+	{
+		name: "RFC 8439 A.3 #10",
+		key:  fromHex("01000000000000000400000000000000 00000000000000000000000000000000"),
+		msg: fromHex("e33594d7505e43b90000000000000000" +
+			"3394d7505e4379cd0100000000000000" +
+			"00000000000000000000000000000000" +
+			"01000000000000000000000000000000"),
+		tag: fromHex("14000000000000005500000000000000"),
+	},
+	{
+		name: "RFC 8439 A.3 #11",
+		key:  fromHex("01000000000000000400000000000000 00000000000000000000000000000000"),
+		msg: fromHex("e33594d7505e43b90000000000000000" +
+			"3394d7505e4379cd0100000000000000" +
+			"00000000000000000000000000000000"),
+		tag: fromHex("13000000000000000000000000000000"),
+	},
+}
+
+func Test_Sum(t *testing.T) {
+	for _, v := range testVectors {
+		tag := Sum(v.msg, v.key)
+		if !shared.AreByteSlicesEqual(tag, v.tag) {
+			t.Errorf("%s: invalid tag %x", v.name, tag)
+		}
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	for _, v := range testVectors {
+		if !Verify(v.tag, v.msg, v.key) {
+			t.Errorf("%s: valid tag rejected", v.name)
+		}
+
+		tag := make([]byte, len(v.tag))
+		copy(tag, v.tag)
+		tag[len(tag)-1] ^= 0x80
+		if Verify(tag, v.msg, v.key) {
+			t.Errorf("%s: modified tag accepted", v.name)
+		}
+		if Verify(v.tag[:TagSize-1], v.msg, v.key) {
+			t.Errorf("%s: truncated tag accepted", v.name)
+		}
+	}
+}
+
+// Test_MACWrite checks if writing the message in pieces
+// of every size produces the same tag as the one-shot Sum
+func Test_MACWrite(t *testing.T) {
+	for _, v := range testVectors {
+		for step := 1; step <= len(v.msg); step++ {
+			m := New(v.key)
+			for i := 0; i < len(v.msg); i += step {
+				end := i + step
+				if end > len(v.msg) {
+					end = len(v.msg)
+				}
+				m.Write(v.msg[i:end])
+			}
+			if !m.Verify(v.tag) {
+				t.Errorf("%s: invalid tag with step %d", v.name, step)
+			}
+		}
+	}
+}
+
+// Test_MACSum checks if Sum doesn't change
+// the state of the MAC object
+func Test_MACSum(t *testing.T) {
+	v := testVectors[4]
+	half := len(v.msg) / 2
+
+	m := New(v.key)
+	m.Write(v.msg[:half])
+	m.Sum(nil)
+	m.Write(v.msg[half:])
+
+	prefix := []byte{0xaa}
+	tag := m.Sum(prefix)
+	if !shared.AreByteSlicesEqual(tag[1:], v.tag) || tag[0] != 0xaa {
+		t.Error("Sum changed the state of MAC")
+	}
+}
+
+func BenchmarkSum(b *testing.B) {
+	key := make([]byte, KeySize)
+	msg := make([]byte, 8*1024)
+
+	b.SetBytes(int64(len(msg)))
+	for n := 0; n < b.N; n++ {
+		Sum(msg, key)
+	}
+}
+
+// Test_TimingVerify checks that verification of the correct
+// tag (fixed class) and random tags takes the same time
+func Test_TimingVerify(t *testing.T) {
+	dudect.SkipUnlessEnabled(t)
+	key := testVectors[0].key
+	m := New(key)
+	m.Write(make([]byte, 1000))
+	correct := m.Sum(nil)
+	tag := make([]byte, TagSize)
+
+	result := dudect.Run(20000, 20, func(class int) {
+		copy(tag, correct)
+		if class == dudect.Random {
+			for i := range tag {
+				tag[i] = byte(rand.Uint32())
+			}
+		}
+	}, func() {
+		dudect.Sink = m.Verify(tag)
+	})
+	t.Log(result)
+	if result.Leaks() {
+		t.Errorf("timing depends on the tag, %v", result)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Package rng implements random numbers generator based on ChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package rng
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"ChaCha-Go/chacha"
+)
+
+// ChaCha8 reproduces the ChaCha8Rand generator of the Go runtime and
+// math/rand/v2.ChaCha8 (https://c2sp.org/chacha8rand) byte for byte,
+// including Read and the binary encoding of the state.
+//
+// Every iteration takes 32 bytes of seed as ChaCha8 key with zero nonce
+// and computes 16 blocks (counters 0 to 15). The constants and the counter
+// are subtracted from the words 0-3 and 12, i.e. only the key words are
+// added after the rounds. Every 4 blocks are interleaved word by word
+// (the first words of the 4 blocks, then the second words, and so on)
+// and read as 128 little endian uint64 values. The last 4 values are
+// the seed of the next iteration, the other 124 are the output.
+//
+// Like math/rand/v2.ChaCha8 the current seed stays in the state until
+// the end of the iteration. ChaCha8 is not safe for concurrent use.
+type ChaCha8 struct {
+	seed    [SeedSize]byte
+	buf     [chacha8Values]uint64
+	i       int // next value of buf
+	readBuf [8]byte
+	readLen int // not consumed bytes at the end of readBuf
+}
+
+const (
+	chacha8Values = 16 * 64 / 8       // uint64 values of an iteration
+	chacha8Output = chacha8Values - 4 // values returned from an iteration
+)
+
+var chacha8Constants = [4]uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574}
+
+var errChaCha8Encoding = errors.New("invalid ChaCha8 encoding")
+
+// NewChaCha8 creates generator which returns the same values
+// as math/rand/v2.NewChaCha8(seed)
+func NewChaCha8(seed [SeedSize]byte) *ChaCha8 {
+	c := new(ChaCha8)
+	c.Seed(seed)
+	return c
+}
+
+// Seed resets the generator to the state created by NewChaCha8(seed)
+func (c *ChaCha8) Seed(seed [SeedSize]byte) {
+	c.seed = seed
+	c.iterate()
+	c.i = 0
+	c.readLen = 0
+	c.readBuf = [8]byte{}
+}
+
+// Uint64 returns the next value
+func (c *ChaCha8) Uint64() uint64 {
+	if c.i == chacha8Output {
+		for j := 0; j < 4; j++ {
+			binary.LittleEndian.PutUint64(c.seed[8*j:], c.buf[chacha8Output+j])
+		}
+		c.iterate()
+		c.i = 0
+	}
+	x := c.buf[c.i]
+	c.i++
+	return x
+}
+
+// Read fills p with the values in little endian order, the same way
+// as math/rand/v2.ChaCha8.Read. It always returns len(p) and nil error.
+func (c *ChaCha8) Read(p []byte) (n int, err error) {
+	if c.readLen > 0 {
+		n = copy(p, c.readBuf[len(c.readBuf)-c.readLen:])
+		c.readLen -= n
+		p = p[n:]
+	}
+	for len(p) >= 8 {
+		binary.LittleEndian.PutUint64(p, c.Uint64())
+		p = p[8:]
+		n += 8
+	}
+	if len(p) > 0 {
+		binary.LittleEndian.PutUint64(c.readBuf[:], c.Uint64())
+		n += copy(p, c.readBuf[:])
+		c.readLen = 8 - len(p)
+	}
+	return n, nil
+}
+
+// MarshalBinary encodes the state in the format
+// of math/rand/v2.ChaCha8.MarshalBinary
+func (c *ChaCha8) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, 64)
+	if c.readLen > 0 {
+		data = append(data, "readbuf:"...)
+		data = append(data, byte(c.readLen))
+		data = append(data, c.readBuf[len(c.readBuf)-c.readLen:]...)
+	}
+	data = append(data, "chacha8:"...)
+	data = binary.BigEndian.AppendUint64(data, uint64(c.i))
+	return append(data, c.seed[:]...), nil
+}
+
+// UnmarshalBinary decodes the state encoded by MarshalBinary
+// or by math/rand/v2.ChaCha8.MarshalBinary
+func (c *ChaCha8) UnmarshalBinary(data []byte) error {
+	var readBuf []byte
+	if len(data) >= 9 && string(data[:8]) == "readbuf:" {
+		n := int(data[8])
+		if n > len(c.readBuf) || len(data) < 9+n {
+			return errChaCha8Encoding
+		}
+		readBuf = data[9 : 9+n]
+		data = data[9+n:]
+	}
+	if len(data) != 8+8+SeedSize || string(data[:8]) != "chacha8:" {
+		return errChaCha8Encoding
+	}
+	used := binary.BigEndian.Uint64(data[8:])
+	if used > chacha8Output {
+		return errChaCha8Encoding
+	}
+
+	copy(c.seed[:], data[16:])
+	c.iterate()
+	c.i = int(used)
+	c.readLen = copy(c.readBuf[len(c.readBuf)-len(readBuf):], readBuf)
+	return nil
+}
+
+// iterate computes the values of the iteration from the seed
+func (c *ChaCha8) iterate() {
+	var keyStream [chacha8Values * 8]byte
+	var nonce [chacha.NonceSize]byte
+	cc, _ := chacha.NewChaCha8(c.seed[:], nonce[:], 0)
+	cc.XORKeyStreamAt(keyStream[:], keyStream[:], 0)
+
+	var group [64]uint32 // 4 interleaved blocks
+	for g := 0; g < 4; g++ {
+		for b := 0; b < 4; b++ {
+			block := keyStream[(4*g+b)*64:]
+			for w := 0; w < 16; w++ {
+				x := binary.LittleEndian.Uint32(block[4*w:])
+				switch {
+				case w < 4:
+					x -= chacha8Constants[w]
+				case w == 12:
+					x -= uint32(4*g + b)
+				}
+				group[4*w+b] = x
+			}
+		}
+		for k := 0; k < 32; k++ {
+			c.buf[32*g+k] = uint64(group[2*k]) | uint64(group[2*k+1])<<32
+		}
+	}
+}
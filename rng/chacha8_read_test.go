@@ -0,0 +1,77 @@
+//go:build go1.23
+
+/*
+Package rng implements random numbers generator based on ChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package rng
+
+import (
+	"math/rand/v2"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+// The tests of Read need math/rand/v2.ChaCha8.Read, added in Go 1.23
+
+func Test_ChaCha8MathRandRead(t *testing.T) {
+	ours, theirs := NewChaCha8(chacha8Seed()), rand.NewChaCha8(chacha8Seed())
+
+	// Read with lengths which are not multiples of 8
+	for n := 0; n < 40; n++ {
+		a, b := make([]byte, n), make([]byte, n)
+		ours.Read(a)
+		theirs.Read(b)
+		if !shared.AreByteSlicesEqual(a, b) {
+			t.Fatalf("Read(%d) differs", n)
+		}
+	}
+}
+
+// Test_ChaCha8MarshalRead checks the encoding
+// with bytes left from the last Read
+func Test_ChaCha8MarshalRead(t *testing.T) {
+	ours, theirs := NewChaCha8(chacha8Seed()), rand.NewChaCha8(chacha8Seed())
+	buf := make([]byte, 3)
+	ours.Read(buf)
+	theirs.Read(buf)
+
+	a, _ := ours.MarshalBinary()
+	b, _ := theirs.MarshalBinary()
+	if !shared.AreByteSlicesEqual(a, b) {
+		t.Fatalf("state %x differs from %x", a, b)
+	}
+
+	restored := new(ChaCha8)
+	if err := restored.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary returned %v", err)
+	}
+	x, y := make([]byte, 20), make([]byte, 20)
+	restored.Read(x)
+	theirs.Read(y)
+	if !shared.AreByteSlicesEqual(x, y) {
+		t.Error("Read after restoring differs")
+	}
+}
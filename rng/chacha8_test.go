@@ -0,0 +1,117 @@
+/*
+Package rng implements random numbers generator based on ChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package rng
+
+import (
+	"encoding/hex"
+	"math/rand/v2"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+func chacha8Seed() [SeedSize]byte {
+	var seed [SeedSize]byte
+	copy(seed[:], "chacha8rand example seed 0123456")
+	return seed
+}
+
+func Test_ChaCha8Captured(t *testing.T) {
+	// captured from math/rand/v2.NewChaCha8, values 0-3 and 122-127
+	// (124 is the first value after reseeding)
+	expected := map[int]uint64{
+		0:   0x0524ad8b446ce940,
+		1:   0x1bc2ddc88c48c056,
+		2:   0x72b7fde0f5655531,
+		3:   0xf909b1fd4d9f160f,
+		122: 0x172cd01be8c886a2,
+		123: 0xb96e52f34b70b56b,
+		124: 0x0af01d6d92907871,
+		125: 0x99bc97459a6b4b7a,
+		126: 0x46806ce9f9577e55,
+		127: 0x310d36bebce512c4,
+	}
+	c := NewChaCha8(chacha8Seed())
+	for i := 0; i < 128; i++ {
+		x := c.Uint64()
+		if v, ok := expected[i]; ok && x != v {
+			t.Errorf("value %d: got %#016x, expected %#016x", i, x, v)
+		}
+	}
+	state, _ := c.MarshalBinary()
+	expectedState, _ := hex.DecodeString("636861636861383a0000000000000004dcd43c4cc157810a9a4985d63dfea77cf5f1d364d338807b55fb939c13377f1f")
+	if !shared.AreByteSlicesEqual(state, expectedState) {
+		t.Errorf("invalid state %x", state)
+	}
+}
+
+func Test_ChaCha8MathRand(t *testing.T) {
+	for s := 0; s < 4; s++ {
+		seed := chacha8Seed()
+		seed[0] = byte(s)
+		ours, theirs := NewChaCha8(seed), rand.NewChaCha8(seed)
+		for i := 0; i < 1000; i++ {
+			if x, y := ours.Uint64(), theirs.Uint64(); x != y {
+				t.Fatalf("seed %d, value %d: got %#016x, expected %#016x", s, i, x, y)
+			}
+		}
+	}
+}
+
+func Test_ChaCha8Marshal(t *testing.T) {
+	ours, theirs := NewChaCha8(chacha8Seed()), rand.NewChaCha8(chacha8Seed())
+	for i := 0; i < 300; i++ {
+		ours.Uint64()
+		theirs.Uint64()
+	}
+	a, _ := ours.MarshalBinary()
+	b, _ := theirs.MarshalBinary()
+	if !shared.AreByteSlicesEqual(a, b) {
+		t.Fatalf("state %x differs from %x", a, b)
+	}
+
+	// continue the stream of math/rand/v2 and the other way
+	restored := new(ChaCha8)
+	if err := restored.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary returned %v", err)
+	}
+	other := rand.NewChaCha8([SeedSize]byte{})
+	if err := other.UnmarshalBinary(a); err != nil {
+		t.Fatalf("math/rand/v2 UnmarshalBinary returned %v", err)
+	}
+	for i := 0; i < 300; i++ {
+		x, y, z := restored.Uint64(), other.Uint64(), theirs.Uint64()
+		if x != z || y != z {
+			t.Fatalf("value %d after restoring differs", i)
+		}
+	}
+
+	for _, data := range [][]byte{nil, []byte("chacha8:"), append(a[:len(a)-1:len(a)-1], 0, 0), []byte("readbuf:\x09")} {
+		if err := restored.UnmarshalBinary(data); err == nil {
+			t.Errorf("invalid encoding %q accepted", data)
+		}
+	}
+}
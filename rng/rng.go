@@ -0,0 +1,174 @@
+/*
+Package rng implements random numbers generator based on ChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package rng
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+
+	"ChaCha-Go/chacha"
+)
+
+const (
+	SeedSize = chacha.KeySize // in bytes
+
+	// DefaultReseedInterval is the number of bytes
+	// between reseeds of generators created by NewSecure
+	DefaultReseedInterval = 1024 * 1024
+
+	bufferSize = 16 * 64 // in bytes, keystream computed at once
+)
+
+var zeroNonce [chacha.NonceSize]byte
+
+// Generator is a random numbers generator based on ChaCha20.
+// It implements io.Reader and math/rand/v2.Source, and it's
+// not safe for concurrent use.
+//
+// Security properties:
+//
+// The generator is the ChaCha20 keystream with a 256-bit key and a zero
+// nonce, used with fast key erasure: every refill computes 1 KiB
+// of keystream, the first 32 bytes replace the key and the rest is
+// the output. The bytes are erased from the buffer as they are returned.
+//
+//   - Seeded with New, the output is fully determined by the seed and
+//     is reproducible, it is as unpredictable as the seed is secret.
+//     The stream is stable across versions.
+//   - Compromise of the state doesn't reveal the output returned before
+//     (backtracking resistance), the old keys are overwritten.
+//     Up to 992 bytes of the next output are kept in the
+//     buffer, they are revealed together with the state.
+//   - Reseeding (Reseed, SetReseedInterval, NewSecure) mixes 32 bytes from
+//     crypto/rand into the key, so the output becomes unpredictable again
+//     after a compromise of the state (prediction resistance), at the cost
+//     of reproducibility.
+type Generator struct {
+	key            [SeedSize]byte
+	buffer         [bufferSize]byte
+	pos            int    // first unused byte of the buffer
+	reseedInterval uint64 // in bytes, 0 means never
+	sinceReseed    uint64 // bytes returned since the last reseed
+}
+
+// New creates generator with passed seed. The same seed
+// gives the same output, the generator never reseeds
+// unless requested with Reseed or SetReseedInterval.
+func New(seed [SeedSize]byte) *Generator {
+	g := new(Generator)
+	g.Seed(seed)
+	return g
+}
+
+// NewSecure creates generator seeded from crypto/rand
+// which reseeds after every DefaultReseedInterval bytes
+func NewSecure() (*Generator, error) {
+	var seed [SeedSize]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	g := New(seed)
+	clear(seed[:])
+	g.SetReseedInterval(DefaultReseedInterval)
+	return g, nil
+}
+
+// Seed resets the generator to the state created by New(seed).
+// The reseed interval is not changed.
+func (g *Generator) Seed(seed [SeedSize]byte) {
+	g.key = seed
+	clear(g.buffer[:])
+	g.pos = bufferSize
+	g.sinceReseed = 0
+}
+
+// SetReseedInterval sets the number of bytes after which the key is mixed
+// with fresh bytes from crypto/rand, zero disables automatic reseeding
+func (g *Generator) SetReseedInterval(n uint64) {
+	g.reseedInterval = n
+}
+
+// Reseed mixes 32 bytes from crypto/rand into the key.
+// The buffered output is discarded.
+func (g *Generator) Reseed() error {
+	var entropy [SeedSize]byte
+	if _, err := rand.Read(entropy[:]); err != nil {
+		return err
+	}
+	for i := range g.key {
+		g.key[i] ^= entropy[i]
+	}
+	clear(entropy[:])
+	clear(g.buffer[:])
+	g.pos = bufferSize
+	g.sinceReseed = 0
+	return nil
+}
+
+// Read fills p with random bytes. It returns an error
+// only if an automatic reseed fails.
+func (g *Generator) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if g.pos == bufferSize {
+			if err := g.refill(); err != nil {
+				return n, err
+			}
+		}
+		m := copy(p[n:], g.buffer[g.pos:])
+		clear(g.buffer[g.pos : g.pos+m])
+		g.pos += m
+		g.sinceReseed += uint64(m)
+		n += m
+	}
+	return n, nil
+}
+
+// Uint64 returns the next 8 bytes of output as little endian number.
+// It panics if an automatic reseed fails.
+func (g *Generator) Uint64() uint64 {
+	var b [8]byte
+	if _, err := g.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// refill computes the next keystream with the current key,
+// replaces the key (fast key erasure) and reseeds if needed
+func (g *Generator) refill() error {
+	if g.reseedInterval > 0 && g.sinceReseed >= g.reseedInterval {
+		if err := g.Reseed(); err != nil {
+			return err
+		}
+	}
+	clear(g.buffer[:])
+	chacha.New(g.key[:], zeroNonce[:], 0).XORKeyStreamAt(g.buffer[:], g.buffer[:], 0)
+	copy(g.key[:], g.buffer[:SeedSize])
+	clear(g.buffer[:SeedSize])
+	g.pos = SeedSize
+	return nil
+}
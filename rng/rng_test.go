@@ -0,0 +1,174 @@
+/*
+Package rng implements random numbers generator based on ChaCha20
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package rng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"ChaCha-Go/chacha"
+	"ChaCha-Go/shared"
+)
+
+// interface check
+var (
+	_ io.Reader   = (*Generator)(nil)
+	_ rand.Source = (*Generator)(nil)
+)
+
+func testSeed() [SeedSize]byte {
+	var seed [SeedSize]byte
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	return seed
+}
+
+func Test_KnownAnswer(t *testing.T) {
+	// RFC 8439 A.1 #1, the second half of block 0 (the key is zero)
+	expected, _ := hex.DecodeString("da41597c5157488d7724e03fb8d84a376a43b8f41518a11cc387b669b2ee6586")
+	out := make([]byte, len(expected))
+	New([SeedSize]byte{}).Read(out)
+	if !shared.AreByteSlicesEqual(out, expected) {
+		t.Errorf("invalid output %x", out)
+	}
+}
+
+// Test_KeyErasure checks the output against the keystream
+// of the keys taken from the previous refills
+func Test_KeyErasure(t *testing.T) {
+	seed := testSeed()
+	out := make([]byte, 3*(bufferSize-SeedSize))
+	New(seed).Read(out)
+
+	key := seed[:]
+	for i := 0; i < 3; i++ {
+		block := make([]byte, bufferSize)
+		chacha.New(key, zeroNonce[:], 0).XORKeyStreamAt(block, block, 0)
+		part := out[i*(bufferSize-SeedSize) : (i+1)*(bufferSize-SeedSize)]
+		if !shared.AreByteSlicesEqual(part, block[SeedSize:]) {
+			t.Errorf("refill %d: invalid output", i)
+		}
+		key = block[:SeedSize]
+	}
+}
+
+func Test_Erased(t *testing.T) {
+	g := New(testSeed())
+	out := make([]byte, 100)
+	g.Read(out)
+	if !bytes.Equal(g.buffer[:SeedSize+100], make([]byte, SeedSize+100)) {
+		t.Error("returned output not erased from the buffer")
+	}
+	if g.key == testSeed() {
+		t.Error("seed not erased")
+	}
+}
+
+func Test_Reproducible(t *testing.T) {
+	a, b := New(testSeed()), New(testSeed())
+	bufA, bufB := make([]byte, 5000), make([]byte, 5000)
+	a.Read(bufA)
+	// the same output read in pieces and as numbers
+	for i := 0; i < len(bufB); {
+		if i%3 == 0 && i+8 <= len(bufB) {
+			binary.LittleEndian.PutUint64(bufB[i:], b.Uint64())
+			i += 8
+			continue
+		}
+		n := min(i%17+1, len(bufB)-i)
+		b.Read(bufB[i : i+n])
+		i += n
+	}
+	if !shared.AreByteSlicesEqual(bufA, bufB) {
+		t.Error("the same seed gives different output")
+	}
+
+	a.Seed(testSeed())
+	a.Read(bufB)
+	if !shared.AreByteSlicesEqual(bufA, bufB) {
+		t.Error("Seed doesn't reset the generator")
+	}
+
+	r1, r2 := rand.New(New(testSeed())), rand.New(New(testSeed()))
+	for i := 0; i < 100; i++ {
+		if r1.IntN(1000) != r2.IntN(1000) {
+			t.Fatal("math/rand/v2 outputs differ")
+		}
+	}
+}
+
+func Test_Reseed(t *testing.T) {
+	a, b := New(testSeed()), New(testSeed())
+	a.SetReseedInterval(2000)
+	bufA, bufB := make([]byte, 5000), make([]byte, 5000)
+	a.Read(bufA)
+	b.Read(bufB)
+	// reseeding happens at the first refill after 2000 bytes
+	if !shared.AreByteSlicesEqual(bufA[:2*(bufferSize-SeedSize)], bufB[:2*(bufferSize-SeedSize)]) {
+		t.Error("output changed before reseeding")
+	}
+	if shared.AreByteSlicesEqual(bufA[3*(bufferSize-SeedSize):], bufB[3*(bufferSize-SeedSize):]) {
+		t.Error("output not changed after reseeding")
+	}
+
+	a.Seed(testSeed())
+	if err := a.Reseed(); err != nil {
+		t.Fatalf("Reseed returned %v", err)
+	}
+	a.Read(bufA)
+	if bytes.Equal(bufA[:100], bufB[:100]) {
+		t.Error("Reseed doesn't change the output")
+	}
+
+	g, err := NewSecure()
+	if err != nil {
+		t.Fatalf("NewSecure returned %v", err)
+	}
+	if g.reseedInterval != DefaultReseedInterval {
+		t.Error("NewSecure doesn't reseed")
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	g := New(testSeed())
+	buf := make([]byte, 4096)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		g.Read(buf)
+	}
+}
+
+func BenchmarkUint64(b *testing.B) {
+	g := New(testSeed())
+	for i := 0; i < b.N; i++ {
+		g.Uint64()
+	}
+}
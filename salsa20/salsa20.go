@@ -0,0 +1,263 @@
+/*
+Package salsa20 implements Salsa20, HSalsa20 and XSalsa20 algorithms
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package salsa20
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+)
+
+const (
+	KeySize   = 32 // in bytes
+	NonceSize = 8  // in bytes
+
+	blockSize int = 64 // in bytes
+)
+
+// ErrCounterOverflow is returned when the text is too long for
+// the 64-bit block counter. Wrapping the counter would reuse the keystream.
+var ErrCounterOverflow = errors.New("salsa20: block counter overflow")
+
+// KeySizeError is returned for key of invalid length
+type KeySizeError int
+
+func (k KeySizeError) Error() string {
+	return "salsa20: invalid key size " + strconv.Itoa(int(k))
+}
+
+// NonceSizeError is returned for nonce of invalid length
+type NonceSizeError int
+
+func (n NonceSizeError) Error() string {
+	return "salsa20: invalid nonce size " + strconv.Itoa(int(n))
+}
+
+// Salsa20 cipher object declaration
+type Salsa20 struct {
+	state      [16]uint32 // constants, key and nonce, the counter is set per block
+	blockCount uint64     // A 64-bit block count parameter
+	rounds     int        // 20, 12 or 8
+}
+
+// NewCipher creates new Salsa20/20 cipher object. It returns KeySizeError
+// or NonceSizeError if the key is not 32 bytes or the nonce is not 8 bytes.
+func NewCipher(key, nonce []byte, blockCount uint64) (*Salsa20, error) {
+	if len(key) != KeySize {
+		return nil, KeySizeError(len(key))
+	}
+	if len(nonce) != NonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+
+	s := &Salsa20{
+		blockCount: blockCount,
+		rounds:     20,
+	}
+	setConstants(&s.state)
+	setKey(&s.state, key)
+	s.state[6] = bytes2word(nonce[0:])
+	s.state[7] = bytes2word(nonce[4:])
+	return s, nil
+}
+
+// New creates new Salsa20/20 cipher object.
+// It panics if the key or the nonce has invalid length, use NewCipher
+// to get an error instead.
+func New(key, nonce []byte, blockCount uint64) *Salsa20 {
+	s, err := NewCipher(key, nonce, blockCount)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewSalsa2012 creates new cipher object with 12 rounds (Salsa20/12)
+func NewSalsa2012(key, nonce []byte, blockCount uint64) (*Salsa20, error) {
+	return newReducedRounds(key, nonce, blockCount, 12)
+}
+
+// NewSalsa208 creates new cipher object with 8 rounds (Salsa20/8)
+func NewSalsa208(key, nonce []byte, blockCount uint64) (*Salsa20, error) {
+	return newReducedRounds(key, nonce, blockCount, 8)
+}
+
+func newReducedRounds(key, nonce []byte, blockCount uint64, rounds int) (*Salsa20, error) {
+	s, err := NewCipher(key, nonce, blockCount)
+	if err != nil {
+		return nil, err
+	}
+	s.rounds = rounds
+	return s, nil
+}
+
+// Rounds returns the number of rounds of the cipher object
+func (s *Salsa20) Rounds() int {
+	return s.rounds
+}
+
+// Cipher encrypts/decrypts passed bytes slice.
+// It panics with ErrCounterOverflow if the text is too long
+// for the block counter, use CipherChecked to get an error instead.
+func (s *Salsa20) Cipher(text []byte) []byte {
+	cipherText, err := s.CipherChecked(text)
+	if err != nil {
+		panic(err)
+	}
+	return cipherText
+}
+
+// CipherChecked encrypts/decrypts passed bytes slice.
+// Empty text gives empty result. It returns ErrCounterOverflow
+// if the text needs more blocks than the block counter
+// has left after the block count of the cipher object.
+func (s *Salsa20) CipherChecked(text []byte) ([]byte, error) {
+	blocks := (uint64(len(text)) + uint64(blockSize) - 1) / uint64(blockSize)
+	if blocks > 0 && blocks-1 > math.MaxUint64-s.blockCount {
+		return nil, ErrCounterOverflow
+	}
+
+	cipherText := make([]byte, len(text))
+	s.NewStream().XORKeyStream(cipherText, text)
+	return cipherText, nil
+}
+
+// Block rotate passed state (20 rounds)
+func Block(state []uint32) []uint32 {
+	var (
+		x   [16]uint32
+		out [blockSize]byte
+	)
+	copy(x[:], state)
+	blockRounds(&out, &x, 20)
+
+	words := make([]uint32, 16)
+	for i := range words {
+		words[i] = bytes2word(out[4*i:])
+	}
+	return words
+}
+
+// keyStream writes keystream block with passed block counter to out
+func (s *Salsa20) keyStream(out *[blockSize]byte, counter uint64) {
+	state := s.state
+	updateStateCounter(&state, counter)
+	blockRounds(out, &state, s.rounds)
+}
+
+// blockRounds computes keystream block of passed state
+// and writes it to out. The state is not modified.
+func blockRounds(out *[blockSize]byte, state *[16]uint32, rounds int) {
+	x := *state
+	doubleRounds(&x, rounds)
+	for i, v := range x {
+		binary.LittleEndian.PutUint32(out[4*i:], v+state[i])
+	}
+}
+
+// doubleRounds applies rounds/2 double rounds, each is
+// a 'column' and a 'row' round, to passed state
+func doubleRounds(state *[16]uint32, rounds int) {
+	x0, x1, x2, x3 := state[0], state[1], state[2], state[3]
+	x4, x5, x6, x7 := state[4], state[5], state[6], state[7]
+	x8, x9, x10, x11 := state[8], state[9], state[10], state[11]
+	x12, x13, x14, x15 := state[12], state[13], state[14], state[15]
+
+	for i := 0; i < rounds; i += 2 {
+		// 'column' round
+		x0, x4, x8, x12 = quarterRound(x0, x4, x8, x12)
+		x5, x9, x13, x1 = quarterRound(x5, x9, x13, x1)
+		x10, x14, x2, x6 = quarterRound(x10, x14, x2, x6)
+		x15, x3, x7, x11 = quarterRound(x15, x3, x7, x11)
+		// 'row' round
+		x0, x1, x2, x3 = quarterRound(x0, x1, x2, x3)
+		x5, x6, x7, x4 = quarterRound(x5, x6, x7, x4)
+		x10, x11, x8, x9 = quarterRound(x10, x11, x8, x9)
+		x15, x12, x13, x14 = quarterRound(x15, x12, x13, x14)
+	}
+
+	state[0], state[1], state[2], state[3] = x0, x1, x2, x3
+	state[4], state[5], state[6], state[7] = x4, x5, x6, x7
+	state[8], state[9], state[10], state[11] = x8, x9, x10, x11
+	state[12], state[13], state[14], state[15] = x12, x13, x14, x15
+}
+
+func quarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+	b ^= bits.RotateLeft32(a+d, 7)
+	c ^= bits.RotateLeft32(b+a, 9)
+	d ^= bits.RotateLeft32(c+b, 13)
+	a ^= bits.RotateLeft32(d+c, 18)
+	return a, b, c, d
+}
+
+func updateStateCounter(state *[16]uint32, counter uint64) {
+	state[8] = uint32(counter)
+	state[9] = uint32(counter >> 32)
+}
+
+// InitState creates initial state with passed block count
+func (s *Salsa20) InitState(blockCount uint64) []uint32 {
+	state := s.state
+	updateStateCounter(&state, blockCount)
+	return state[:]
+}
+
+// setConstants sets "expand 32-byte k" on the diagonal of the state
+func setConstants(state *[16]uint32) {
+	state[0] = 0x61707865
+	state[5] = 0x3320646e
+	state[10] = 0x79622d32
+	state[15] = 0x6b206574
+}
+
+// setKey sets the first half of the key in words 1-4
+// and the second half in words 11-14 of the state
+func setKey(state *[16]uint32, key []byte) {
+	for i := 0; i < 4; i++ {
+		state[i+1] = bytes2word(key[4*i:])
+		state[i+11] = bytes2word(key[16+4*i:])
+	}
+}
+
+// Serialize converts uint32 slice to bytes slice
+func Serialize(data []uint32) []byte {
+	n := len(data)
+	if n == 0 {
+		return nil
+	}
+
+	out := make([]byte, n*4)
+	for i, v := range data {
+		binary.LittleEndian.PutUint32(out[4*i:], v)
+	}
+	return out
+}
+
+func bytes2word(data []byte) uint32 {
+	return binary.LittleEndian.Uint32(data)
+}
@@ -0,0 +1,329 @@
+/*
+Package salsa20 implements Salsa20, HSalsa20 and XSalsa20 algorithms
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package salsa20
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"ChaCha-Go/shared"
+)
+
+func fromHex(s string) []byte {
+	data, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// interface check
+var _ cipher.Stream = (*Stream)(nil)
+
+func Test_quarterRound(t *testing.T) {
+	// Salsa20 specification, section 3
+	a, b, c, d := quarterRound(0x00000001, 0x00000000, 0x00000000, 0x00000000)
+	if a != 0x08008145 || b != 0x00000080 || c != 0x00010200 || d != 0x20500000 {
+		t.Error("quarter round don't works")
+	}
+	a, b, c, d = quarterRound(0xe7e8c006, 0xc4f9417d, 0x6479b4b2, 0x68c67137)
+	if a != 0xe876d72b || b != 0x9361dfd5 || c != 0xf1460244 || d != 0x948541a3 {
+		t.Error("quarter round don't works")
+	}
+}
+
+func Test_Block8(t *testing.T) {
+	// Salsa20/8 core of bytes 0x00..0x3f, checked against scrypt's Salsa20/8 core
+	var input [16]uint32
+	for i := range input {
+		input[i] = bytes2word([]byte{byte(4 * i), byte(4*i + 1), byte(4*i + 2), byte(4*i + 3)})
+	}
+	expected := fromHex(
+		"0480a95cad0a1fe3377c65670cf6443d26683f7605af36ad9dcd018d9d18017a" +
+			"aad09751c075fe3547a9e0002388304dac7f8e77c4c0bbe7d90288100c15e705")
+
+	var output [64]byte
+	blockRounds(&output, &input, 8)
+	if !shared.AreByteSlicesEqual(output[:], expected) {
+		t.Error("invalid Salsa20/8 core")
+	}
+}
+
+// Test_Cipher uses eSTREAM vectors for Salsa20/20 with 256-bit keys
+func Test_Cipher(t *testing.T) {
+	key := make([]byte, KeySize)
+	key[0] = 0x80
+	expected := fromHex(
+		"e3be8fdd8beca2e3ea8ef9475b29a6e7003951e1097a5c38d23b7a5fad9f6844" +
+			"b22c97559e2723c7cbbd3fe4fc8d9a0744652a83e72a9c461876af4d7ef1a117")
+
+	s := New(key, make([]byte, NonceSize), 0)
+	if !shared.AreByteSlicesEqual(s.Cipher(make([]byte, 64)), expected) {
+		t.Error("eSTREAM set 1, vector 0: invalid keystream")
+	}
+	if s.Rounds() != 20 {
+		t.Error("invalid number of rounds")
+	}
+
+	// eSTREAM set 6: XOR of all 64-byte blocks of 131072 bytes of keystream
+	vectors := []struct {
+		key   []byte
+		nonce []byte
+		xor   []byte
+	}{
+		{
+			key:   fromHex("0053a6f94c9ff24598eb3e91e4378add3083d6297ccf2275c81b6ec11467ba0d"),
+			nonce: fromHex("0d74db42a91077de"),
+			xor: fromHex("c349b6a51a3ec9b712eaed3f90d8bcee69b7628645f251a996f55260c62ef31f" +
+				"d6c6b0aea94e136c9d984ad2df3578f78e457527b03a0450580dd874f63b1ab9"),
+		},
+		{
+			key:   fromHex("0558abfe51a4f74a9df04396e93c8fe23588db2e81d4277acd2073c6196cbf12"),
+			nonce: fromHex("167de44bb21980e7"),
+			xor: fromHex("c3eaaf32836bace32d04e1124231ef47e101367d6305413a0eeb07c60698a287" +
+				"6e4d031870a739d6ffddd208597aff0a47ac17edb0167dd67eba84f1883d4dfd"),
+		},
+		{
+			key:   fromHex("0a5db00356a9fc4fa2f5489bee4194e73a8de03386d92c7fd22578cb1e71c417"),
+			nonce: fromHex("1f86ed54bb2289f0"),
+			xor: fromHex("3cd23c3dc90201acc0cf49b440b6c417f0dc8d8410a716d5314c059e14b1a8d9" +
+				"a9fb8ea3d9c8dae12b21402f674aa95c67b1fc514e994c9d3f3a6e41dff5bba6"),
+		},
+		{
+			key:   fromHex("0f62b5085bae0154a7fa4da0f34699ec3f92e5388bde3184d72a7dd02376c91c"),
+			nonce: fromHex("288ff65dc42b92f9"),
+			xor: fromHex("e00ebccd70d69152725f9987982178a2e2e139c7bcbe04ca8a0e99e318d9ab76" +
+				"f988c8549f75add790ba4f81c176da653c1a043f11a958e169b6d2319f4eec1a"),
+		},
+	}
+
+	for i, v := range vectors {
+		keyStream := New(v.key, v.nonce, 0).Cipher(make([]byte, 131072))
+		xor := make([]byte, 64)
+		for len(keyStream) > 0 {
+			for k := range xor {
+				xor[k] ^= keyStream[k]
+			}
+			keyStream = keyStream[64:]
+		}
+		if !shared.AreByteSlicesEqual(xor, v.xor) {
+			t.Errorf("eSTREAM set 6, vector %d: invalid keystream", i)
+		}
+	}
+}
+
+func Test_ReducedRounds(t *testing.T) {
+	key := make([]byte, KeySize)
+	key[0] = 0x80
+	nonce := make([]byte, NonceSize)
+
+	s8, _ := NewSalsa208(key, nonce, 0)
+	s12, _ := NewSalsa2012(key, nonce, 0)
+	if s8.Rounds() != 8 || s12.Rounds() != 12 {
+		t.Fatal("invalid number of rounds")
+	}
+
+	// eSTREAM vectors for Salsa20/8 and Salsa20/12 with 256-bit keys,
+	// set 1, vector 0: stream[0..63] and stream[448..511]
+	vectors := []struct {
+		name     string
+		s        *Salsa20
+		expected []byte
+	}{
+		{
+			"Salsa20/8", s8, fromHex(
+				"b1f599e9b0d96df436ae31f5ef589565b92d245db5a1d4c7a78e5e8d0146f8a4" +
+					"9d326c1a3bf50c052c9c8f114dc74972c4469591e31c9ed11927aa9871f38583" +
+					"53bf865c66a344cfcd19177476a05aca5851cc45224b196abf3206d899e7fe3b" +
+					"13b3f028fa849b5564561a9181ea69e512bc34da29180cdf6811e40a9a06a8d1"),
+		},
+		{
+			"Salsa20/12", s12, fromHex(
+				"afe411ed1c4e07e4d0cde3b33e31ec190fa4cc796a58bafb848ead8d07d02cd2" +
+					"d4b6f9f30cb0b57007e3733895cc8d1060107975acaeeb689b6cf614ab64a3d6" +
+					"87a5191ec2e3c9049fa524cd8673e0677c77adcf8ab5328fd828c4acb3eccca5" +
+					"49adeda04872518ecdf874adcb2420c7bd1ccfe561b074080224fa7176f0cb5f"),
+		},
+	}
+	for _, v := range vectors {
+		stream := v.s.Cipher(make([]byte, 512))
+		if !shared.AreByteSlicesEqual(stream[:64], v.expected[:64]) {
+			t.Errorf("%s: invalid stream[0..63]", v.name)
+		}
+		if !shared.AreByteSlicesEqual(stream[448:], v.expected[64:]) {
+			t.Errorf("%s: invalid stream[448..511]", v.name)
+		}
+	}
+}
+
+// Test_HSalsa20 uses the vector of NaCl tests/core1.c
+func Test_HSalsa20(t *testing.T) {
+	key := fromHex("4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742")
+	expected := fromHex("1b27556473e985d462cd51197a9a46c76009549eac6474f206c4ee0844f68389")
+
+	if !shared.AreByteSlicesEqual(HSalsa20(key, make([]byte, HNonceSize)), expected) {
+		t.Error("invalid HSalsa20 subkey")
+	}
+}
+
+func Test_XSalsa20(t *testing.T) {
+	vectors := []struct {
+		name       string
+		key        []byte
+		nonce      []byte
+		plainText  []byte
+		cipherText []byte
+	}{
+		{
+			// NaCl tests/stream3.c
+			name:       "NaCl stream3",
+			key:        fromHex("1b27556473e985d462cd51197a9a46c76009549eac6474f206c4ee0844f68389"),
+			nonce:      fromHex("69696ee955b62b73cd62bda875fc73d68219e0036b7a0b37"),
+			plainText:  make([]byte, 32),
+			cipherText: fromHex("eea6a7251c1e72916d11c2cb214d3c252539121d8e234e652d651fa4c8cff880"),
+		},
+		{
+			name:       "hello world",
+			key:        []byte("this is 32-byte key for xsalsa20"),
+			nonce:      []byte("24-byte nonce for xsalsa"),
+			plainText:  []byte("Hello world!"),
+			cipherText: fromHex("002d4513843fc240c401e541"),
+		},
+		{
+			name:      "zeros",
+			key:       []byte("this is 32-byte key for xsalsa20"),
+			nonce:     []byte("24-byte nonce for xsalsa"),
+			plainText: make([]byte, 64),
+			cipherText: fromHex("4848297feb1fb52fb66d81609bd547fabcbe7026edc8b5e5e449d088bfa69c08" +
+				"8f5d8da1d791267c2c195a7f8cae9c4b4050d08ce6d3a151ec265f3a58e47648"),
+		},
+	}
+
+	for _, v := range vectors {
+		s := NewXSalsa20(v.key, v.nonce, 0)
+		cipherText := s.Cipher(v.plainText)
+		if !shared.AreByteSlicesEqual(cipherText, v.cipherText) {
+			t.Errorf("%s: plain text -> cipher text failed", v.name)
+		}
+		if !shared.AreByteSlicesEqual(s.Cipher(cipherText), v.plainText) {
+			t.Errorf("%s: cipher text -> plain text failed", v.name)
+		}
+	}
+}
+
+func Test_Stream(t *testing.T) {
+	s := New([]byte("this is 32-byte key for xsalsa20"), make([]byte, NonceSize), 7)
+	plainText := make([]byte, 500)
+	for i := range plainText {
+		plainText[i] = byte(i)
+	}
+	expected := s.Cipher(plainText)
+
+	for split := 0; split <= len(plainText); split += 7 {
+		stream := s.NewStream()
+		cipherText := make([]byte, len(plainText))
+		stream.XORKeyStream(cipherText[:split], plainText[:split])
+		stream.XORKeyStream(cipherText[split:], plainText[split:])
+		if !shared.AreByteSlicesEqual(cipherText, expected) {
+			t.Fatalf("invalid output for split at %d", split)
+		}
+	}
+
+	for offset := 0; offset < len(plainText); offset += 13 {
+		cipherText := make([]byte, len(plainText)-offset)
+		s.XORKeyStreamAt(cipherText, plainText[offset:], uint64(offset))
+		if !shared.AreByteSlicesEqual(cipherText, expected[offset:]) {
+			t.Fatalf("invalid output at offset %d", offset)
+		}
+	}
+}
+
+func Test_StreamSetCounter(t *testing.T) {
+	key := []byte("this is 32-byte key for xsalsa20")
+	plainText := make([]byte, 300)
+	expected := New(key, make([]byte, NonceSize), 1).Cipher(plainText)
+
+	// block count of the cipher object is 1, so block 3 starts at offset 128
+	stream := New(key, make([]byte, NonceSize), 1).NewStream()
+	stream.XORKeyStream(make([]byte, 10), plainText[:10])
+	stream.SetCounter(3)
+	buffer := make([]byte, 100)
+	stream.XORKeyStream(buffer, plainText[128:228])
+	if !shared.AreByteSlicesEqual(buffer, expected[128:228]) {
+		t.Error("invalid output after SetCounter")
+	}
+
+	stream.Seek(70)
+	stream.XORKeyStream(buffer, plainText[70:170])
+	if !shared.AreByteSlicesEqual(buffer, expected[70:170]) {
+		t.Error("invalid output after Seek")
+	}
+
+	stream = New(key, make([]byte, NonceSize), 0xffffffffffffffff).NewStream()
+	stream.Seek(64)
+	defer func() {
+		if recover() != ErrCounterOverflow {
+			t.Error("expected ErrCounterOverflow after the last block")
+		}
+	}()
+	stream.XORKeyStream(buffer[:1], plainText[:1])
+}
+
+func Test_XORKeyStreamAtAllocs(t *testing.T) {
+	s := New(make([]byte, KeySize), make([]byte, NonceSize), 0)
+	buffer := make([]byte, 1000)
+	allocs := testing.AllocsPerRun(10, func() {
+		s.XORKeyStreamAt(buffer, buffer, 10)
+	})
+	if allocs != 0 {
+		t.Errorf("XORKeyStreamAt allocates %v times, expected 0", allocs)
+	}
+}
+
+func Test_NewCipher(t *testing.T) {
+	var keyErr KeySizeError
+	if _, err := NewCipher(make([]byte, 16), make([]byte, NonceSize), 0); !errors.As(err, &keyErr) {
+		t.Errorf("expected KeySizeError, got %v", err)
+	}
+	var nonceErr NonceSizeError
+	if _, err := NewCipher(make([]byte, KeySize), make([]byte, 12), 0); !errors.As(err, &nonceErr) {
+		t.Errorf("expected NonceSizeError, got %v", err)
+	}
+	if _, err := NewXCipher(make([]byte, KeySize), make([]byte, NonceSize), 0); !errors.As(err, &nonceErr) {
+		t.Errorf("expected NonceSizeError, got %v", err)
+	}
+
+	s := New(make([]byte, KeySize), make([]byte, NonceSize), 0xffffffffffffffff)
+	if _, err := s.CipherChecked(make([]byte, 64)); err != nil {
+		t.Errorf("last block rejected: %v", err)
+	}
+	if _, err := s.CipherChecked(make([]byte, 65)); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("expected ErrCounterOverflow, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Package salsa20 implements Salsa20, HSalsa20 and XSalsa20 algorithms
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package salsa20
+
+import (
+	"math"
+)
+
+// Stream is stateful Salsa20 keystream implementing cipher.Stream.
+// Subsequent calls of XORKeyStream continue from the position
+// where the previous call stopped.
+type Stream struct {
+	s         *Salsa20
+	base      uint64          // block count of the first keystream block (offset 0)
+	counter   uint64          // block count of the next keystream block
+	exhausted bool            // the block counter has no blocks left
+	keyStream [blockSize]byte // current keystream block
+	offset    int             // index of the first unused byte in keyStream
+}
+
+// NewStream creates stream starting at the block count of the cipher object
+func (s *Salsa20) NewStream() *Stream {
+	return &Stream{
+		s:       s,
+		base:    s.blockCount,
+		counter: s.blockCount,
+		offset:  blockSize,
+	}
+}
+
+// XORKeyStream XORs each byte in src with a byte from the keystream
+// and writes the result to dst. Dst and src must overlap entirely or not at all.
+// It panics with ErrCounterOverflow when the block counter is exhausted.
+func (st *Stream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("salsa20: output smaller than input")
+	}
+
+	for len(src) > 0 {
+		if st.offset == blockSize {
+			st.nextBlock()
+		}
+		n := blockSize - st.offset
+		if n > len(src) {
+			n = len(src)
+		}
+		keyStream := st.keyStream[st.offset : st.offset+n]
+		for i, v := range keyStream {
+			dst[i] = src[i] ^ v
+		}
+		st.offset += n
+		src = src[n:]
+		dst = dst[n:]
+	}
+}
+
+// SetCounter moves the stream to the beginning
+// of the keystream block with passed block count
+func (st *Stream) SetCounter(counter uint64) {
+	st.counter = counter
+	st.exhausted = false
+	st.offset = blockSize
+}
+
+// Seek moves the stream to passed byte offset. The offset is counted
+// from the beginning of the block count of the cipher object.
+func (st *Stream) Seek(offset uint64) {
+	blocks := offset / uint64(blockSize)
+	if blocks > math.MaxUint64-st.base {
+		st.exhausted = true
+		st.offset = blockSize
+		return
+	}
+
+	st.SetCounter(st.base + blocks)
+	if rest := int(offset % uint64(blockSize)); rest != 0 {
+		st.nextBlock()
+		st.offset = rest
+	}
+}
+
+// XORKeyStreamAt encrypts/decrypts src as if it started at passed
+// byte offset of the message and writes the result to dst.
+func (s *Salsa20) XORKeyStreamAt(dst, src []byte, offset uint64) {
+	st := s.NewStream()
+	st.Seek(offset)
+	st.XORKeyStream(dst, src)
+}
+
+func (st *Stream) nextBlock() {
+	if st.exhausted {
+		panic(ErrCounterOverflow)
+	}
+	st.s.keyStream(&st.keyStream, st.counter)
+	if st.counter == math.MaxUint64 {
+		st.exhausted = true
+	} else {
+		st.counter++
+	}
+	st.offset = 0
+}
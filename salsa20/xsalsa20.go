@@ -0,0 +1,87 @@
+/*
+Package salsa20 implements Salsa20, HSalsa20 and XSalsa20 algorithms
+
+MIT License
+
+Copyright (c) 2021 Piotr Pszczółkowski
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+package salsa20
+
+import "encoding/binary"
+
+const (
+	HNonceSize = 16 // in bytes, HSalsa20 nonce
+	XNonceSize = 24 // in bytes, XSalsa20 nonce
+)
+
+// HSalsa20 derives 256-bit subkey from 256-bit key and 128-bit nonce.
+// It panics if the key or the nonce has invalid length.
+func HSalsa20(key, nonce []byte) []byte {
+	if len(key) != KeySize {
+		panic(KeySizeError(len(key)))
+	}
+	if len(nonce) != HNonceSize {
+		panic(NonceSizeError(len(nonce)))
+	}
+
+	var state [16]uint32
+	setConstants(&state)
+	setKey(&state, key)
+	for i := 0; i < 4; i++ {
+		state[i+6] = bytes2word(nonce[4*i:])
+	}
+
+	// HSalsa20 skips the final addition of the initial state
+	doubleRounds(&state, 20)
+
+	subKey := make([]byte, KeySize)
+	for i, v := range [8]uint32{state[0], state[5], state[10], state[15], state[6], state[7], state[8], state[9]} {
+		binary.LittleEndian.PutUint32(subKey[4*i:], v)
+	}
+	return subKey
+}
+
+// NewXCipher creates new XSalsa20 cipher object with 192-bit nonce
+// (as used by NaCl secretbox). First 16 bytes of the nonce derive subkey,
+// remaining 8 bytes make Salsa20 nonce. It returns KeySizeError
+// or NonceSizeError for invalid lengths.
+func NewXCipher(key, nonce []byte, blockCount uint64) (*Salsa20, error) {
+	if len(key) != KeySize {
+		return nil, KeySizeError(len(key))
+	}
+	if len(nonce) != XNonceSize {
+		return nil, NonceSizeError(len(nonce))
+	}
+
+	subKey := HSalsa20(key, nonce[:HNonceSize])
+	return NewCipher(subKey, nonce[HNonceSize:], blockCount)
+}
+
+// NewXSalsa20 creates new XSalsa20 cipher object with 192-bit nonce.
+// It panics if the key or the nonce has invalid length, use NewXCipher
+// to get an error instead.
+func NewXSalsa20(key, nonce []byte, blockCount uint64) *Salsa20 {
+	s, err := NewXCipher(key, nonce, blockCount)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"strings"
 )
@@ -25,7 +26,9 @@ func AreWordSlicesEqual(a, b []uint32) bool {
 }
 
 // AreByteSlicesEqual checks if two
-// passed byte slices are equal
+// passed byte slices are equal.
+// It returns at the first differing byte, so the time depends
+// on the content, use ConstantTimeEqual for secret values.
 func AreByteSlicesEqual(a, b []byte) bool {
 	n := len(a)
 	if n != len(b) {
@@ -43,6 +46,21 @@ func AreByteSlicesEqual(a, b []byte) bool {
 	return true
 }
 
+// ConstantTimeEqual checks if two passed byte slices are equal
+// in time which depends only on their lengths, not on the content.
+// Use it to compare secret values such as MAC tags.
+func ConstantTimeEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// FitBytes returns data cut or padded with zeros to n bytes,
+// e.g. to turn any fuzz input into a valid key or nonce
+func FitBytes(data []byte, n int) []byte {
+	out := make([]byte, n)
+	copy(out, data)
+	return out
+}
+
 // PrintWords prints uint32 slice as hex values
 func PrintWords(state []uint32) {
 	for i, v := range state {
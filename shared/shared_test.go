@@ -0,0 +1,71 @@
+package shared
+
+import (
+	"testing"
+
+	"ChaCha-Go/internal/dudect"
+)
+
+func Test_ConstantTimeEqual(t *testing.T) {
+	cases := []struct {
+		a, b  []byte
+		equal bool
+	}{
+		{nil, nil, true},
+		{[]byte{}, nil, true},
+		{[]byte{1, 2, 3}, []byte{1, 2, 3}, true},
+		{[]byte{1, 2, 3}, []byte{1, 2, 4}, false},
+		{[]byte{0, 2, 3}, []byte{1, 2, 3}, false},
+		{[]byte{1, 2, 3}, []byte{1, 2}, false},
+		{[]byte{0x80}, []byte{0x00}, false},
+	}
+	for _, c := range cases {
+		if ConstantTimeEqual(c.a, c.b) != c.equal || AreByteSlicesEqual(c.a, c.b) != c.equal {
+			t.Errorf("%v, %v: expected %v", c.a, c.b, c.equal)
+		}
+	}
+}
+
+func Test_FitBytes(t *testing.T) {
+	data := []byte{1, 2, 3}
+	if out := FitBytes(data, 2); !AreByteSlicesEqual(out, []byte{1, 2}) {
+		t.Errorf("cut: got %v", out)
+	}
+	if out := FitBytes(data, 5); !AreByteSlicesEqual(out, []byte{1, 2, 3, 0, 0}) {
+		t.Errorf("padded: got %v", out)
+	}
+}
+
+// timeCompare measures passed comparison of equal slices (fixed class)
+// and slices which differ at the first byte (random class)
+func timeCompare(equal func(a, b []byte) bool) dudect.Result {
+	secret := make([]byte, 512)
+	for i := range secret {
+		secret[i] = byte(i)
+	}
+	input := make([]byte, len(secret))
+	return dudect.Run(20000, 20, func(class int) {
+		copy(input, secret)
+		if class == dudect.Random {
+			input[0] ^= 1
+		}
+	}, func() {
+		dudect.Sink = equal(input, secret)
+	})
+}
+
+func Test_TimingConstantTimeEqual(t *testing.T) {
+	dudect.SkipUnlessEnabled(t)
+	result := timeCompare(ConstantTimeEqual)
+	t.Log(result)
+	if result.Leaks() {
+		t.Errorf("ConstantTimeEqual: timing depends on the data, %v", result)
+	}
+
+	// the harness must see the early exit
+	result = timeCompare(AreByteSlicesEqual)
+	t.Log(result)
+	if !result.Leaks() {
+		t.Errorf("AreByteSlicesEqual: early exit not detected, %v", result)
+	}
+}